@@ -0,0 +1,441 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	gogitconfig "github.com/go-git/go-git/v5/config"
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/kevinburke/ssh_config"
+	"github.com/nicjohnson145/tagbot/internal/config"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// defaultSSHUser is who to authenticate as when the remote's url doesn't say, the user every major host expects
+	defaultSSHUser = "git"
+
+	remoteSection = "remote"
+	urlSection    = "url"
+)
+
+// defaultSSHKeyNames are the keys within ~/.ssh tried when no key is configured, in the same order ssh itself does
+var defaultSSHKeyNames = []string{
+	"id_rsa",
+	"id_ecdsa",
+	"id_ed25519",
+}
+
+func (g *GitRepo) initializeAuth(conf GitRepoConfig) error {
+	var authMethod config.AuthKind
+
+	// the url is only needed to detect the auth method, so a remote that can't be found isn't fatal when it's configured
+	url, urlErr := g.remoteURL(conf)
+	if urlErr == nil {
+		g.pushURL = url
+	}
+
+	// if our auth method is explicitly configured, then use that
+	if conf.AuthMethod != "" {
+		meth, err := config.ParseAuthKind(conf.AuthMethod)
+		if err != nil {
+			return err
+		}
+		authMethod = meth
+	} else { // otherwise try and detect it from the url tags will be pushed to
+		if urlErr != nil {
+			return fmt.Errorf("error detecting auth method: %w", urlErr)
+		}
+		meth, err := authMethodFromURL(url)
+		if err != nil {
+			return fmt.Errorf("error detecting auth method: %w", err)
+		}
+		authMethod = meth
+	}
+
+	switch authMethod {
+	case config.AuthKindPublicKey:
+		a, err := g.sshAuth(conf)
+		if err != nil {
+			return fmt.Errorf("error establishing ssh auth: %w", err)
+		}
+		g.auth = a
+	case config.AuthKindSshAgent:
+		a, err := ssh.NewSSHAgentAuth(g.sshUser())
+		if err != nil {
+			return fmt.Errorf("error establishing ssh agent auth: %w", err)
+		}
+		g.auth = a
+	case config.AuthKindToken:
+		a, err := g.tokenAuth(conf)
+		if err != nil {
+			return fmt.Errorf("error establishing token auth: %w", err)
+		}
+		g.auth = a
+	case config.AuthKindNone:
+		g.auth = nil
+	default:
+		return fmt.Errorf("unhandled auth kind %v", authMethod)
+	}
+
+	// Only ssh verifies host keys, leave go-git's default of the user's known_hosts in place unless told otherwise
+	callback, err := hostKeyCallback(conf)
+	if err != nil {
+		return fmt.Errorf("error configuring host key verification: %w", err)
+	}
+	if callback != nil {
+		switch a := g.auth.(type) {
+		case *ssh.PublicKeys:
+			a.HostKeyCallback = callback
+		case *ssh.PublicKeysCallback:
+			a.HostKeyCallback = callback
+		}
+	}
+
+	return nil
+}
+
+// hostKeyCallback builds the callback verifying the remote's host key from the configured known hosts, or nil if none
+// are configured
+func hostKeyCallback(conf GitRepoConfig) (gossh.HostKeyCallback, error) {
+	if conf.InsecureIgnoreHostKey {
+		return gossh.InsecureIgnoreHostKey(), nil
+	}
+
+	files := []string{}
+	if conf.KnownHostsPath != "" {
+		// go-git quietly skips missing files, but a path given explicitly should exist
+		if _, err := os.Stat(conf.KnownHostsPath); err != nil {
+			return nil, fmt.Errorf("error reading known hosts file: %w", err)
+		}
+		files = append(files, conf.KnownHostsPath)
+	}
+
+	if conf.KnownHosts != "" {
+		// known hosts can only be loaded from files, but they're read immediately so this one needn't outlive the call
+		tmp, err := os.CreateTemp("", "tagbot-known-hosts-*")
+		if err != nil {
+			return nil, fmt.Errorf("error creating temporary known hosts file: %w", err)
+		}
+		defer func() {
+			_ = os.Remove(tmp.Name())
+		}()
+		if _, err := tmp.WriteString(conf.KnownHosts + "\n"); err != nil {
+			_ = tmp.Close()
+			return nil, fmt.Errorf("error writing temporary known hosts file: %w", err)
+		}
+		if err := tmp.Close(); err != nil {
+			return nil, fmt.Errorf("error writing temporary known hosts file: %w", err)
+		}
+		files = append(files, tmp.Name())
+	}
+
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	callback, err := ssh.NewKnownHostsCallback(files...)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing known hosts: %w", err)
+	}
+	return callback, nil
+}
+
+// explainHostKeyError rewords failures to verify the remote's host key into something actionable, passing any other
+// error through untouched
+func explainHostKeyError(err error) error {
+	var revokedErr *knownhosts.RevokedError
+	if errors.As(err, &revokedErr) {
+		return fmt.Errorf("host key of remote has been revoked in known hosts: %w", err)
+	}
+
+	var keyErr *knownhosts.KeyError
+	if errors.As(err, &keyErr) {
+		if len(keyErr.Want) == 0 {
+			return fmt.Errorf("host key of remote is not in known hosts, supply it with --known-hosts or --known-hosts-path: %w", err)
+		}
+		return fmt.Errorf("host key of remote does not match known hosts, the remote may be impersonated or its key may have changed: %w", err)
+	}
+
+	return err
+}
+
+// remoteURL returns the url tags are pushed to for the remote. Like git, that's its pushurl if it has one, otherwise its
+// url, with any url.<base>.pushInsteadOf or url.<base>.insteadOf rewrites from the repo, user & system config applied
+func (g *GitRepo) remoteURL(conf GitRepoConfig) (string, error) {
+	local, err := g.repo.Config()
+	if err != nil {
+		return "", fmt.Errorf("error reading git config: %w", err)
+	}
+	// remotes created through go-git only make it into the raw config once it's marshalled
+	if _, err := local.Marshal(); err != nil {
+		return "", fmt.Errorf("error reading git config: %w", err)
+	}
+
+	remote := rawSubsection(local.Raw, remoteSection, conf.Remote)
+	if remote == nil {
+		return "", fmt.Errorf("unable to find remote named '%v'", conf.Remote)
+	}
+
+	configs := []*format.Config{local.Raw}
+	for _, scope := range []gogitconfig.Scope{gogitconfig.GlobalScope, gogitconfig.SystemScope} {
+		c, err := gogitconfig.LoadConfig(scope)
+		if err != nil {
+			return "", fmt.Errorf("error reading git config: %w", err)
+		}
+		configs = append(configs, c.Raw)
+	}
+
+	// pushInsteadOf is deliberately not applied to an explicit pushurl
+	if pushURLs := remote.OptionAll("pushurl"); len(pushURLs) > 0 {
+		url, _ := rewriteURL(configs, "insteadOf", pushURLs[0])
+		return url, nil
+	}
+
+	urls := remote.OptionAll("url")
+	if len(urls) == 0 {
+		return "", fmt.Errorf("remote '%v' has no url", conf.Remote)
+	}
+	if url, ok := rewriteURL(configs, "pushInsteadOf", urls[0]); ok {
+		return url, nil
+	}
+	url, _ := rewriteURL(configs, "insteadOf", urls[0])
+	return url, nil
+}
+
+// rewriteURL replaces the longest prefix of the url matching the given option of any url.<base> section with that base,
+// returning if anything matched
+func rewriteURL(configs []*format.Config, option string, url string) (string, bool) {
+	base := ""
+	longest := -1
+	for _, c := range configs {
+		for _, sub := range rawSubsections(c, urlSection) {
+			for _, prefix := range sub.OptionAll(option) {
+				if strings.HasPrefix(url, prefix) && len(prefix) > longest {
+					base = sub.Name
+					longest = len(prefix)
+				}
+			}
+		}
+	}
+
+	if longest == -1 {
+		return url, false
+	}
+	return base + url[longest:], true
+}
+
+// rawSubsections returns the subsections of the named section, without creating the section like format.Config.Section
+// does when it's missing
+func rawSubsections(c *format.Config, section string) format.Subsections {
+	for _, s := range c.Sections {
+		if s.IsName(section) {
+			return s.Subsections
+		}
+	}
+	return nil
+}
+
+func rawSubsection(c *format.Config, section string, name string) *format.Subsection {
+	for _, sub := range rawSubsections(c, section) {
+		if sub.IsName(name) {
+			return sub
+		}
+	}
+	return nil
+}
+
+func authMethodFromURL(url string) (config.AuthKind, error) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return config.AuthKind(""), fmt.Errorf("error parsing remote url: %w", err)
+	}
+
+	switch endpoint.Protocol {
+	case "ssh":
+		return config.AuthKindPublicKey, nil
+	case "http", "https":
+		return config.AuthKindToken, nil
+	case "file", "git":
+		return config.AuthKindNone, nil
+	}
+
+	return config.AuthKind(""), fmt.Errorf("unable to auto determine auth method for %v remote, please explictly configure", endpoint.Protocol)
+}
+
+// pushEndpoint parses the url tags are pushed to, returning nil if it's unknown
+func (g *GitRepo) pushEndpoint() *transport.Endpoint {
+	if g.pushURL == "" {
+		return nil
+	}
+	endpoint, err := transport.NewEndpoint(g.pushURL)
+	if err != nil {
+		return nil
+	}
+	return endpoint
+}
+
+// sshUser is the user to authenticate as, which is whoever the remote's url names if it names anyone
+func (g *GitRepo) sshUser() string {
+	if endpoint := g.pushEndpoint(); endpoint != nil && endpoint.User != "" {
+		return endpoint.User
+	}
+	return defaultSSHUser
+}
+
+func (g *GitRepo) sshAuth(conf GitRepoConfig) (transport.AuthMethod, error) {
+	keyPath := conf.AuthKeyPath
+	if keyPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("error getting user home directory: %w", err)
+		}
+
+		// the host is only needed to look up its IdentityFile, so a remote that can't be found isn't fatal
+		host := ""
+		if endpoint := g.pushEndpoint(); endpoint != nil {
+			host = endpoint.Host
+		}
+
+		path, err := findSSHKey(filepath.Join(home, ".ssh"), host)
+		if err != nil {
+			return nil, err
+		}
+		keyPath = path
+	}
+
+	// with no key to be found, an agent is the only other place one could be
+	if keyPath == "" {
+		if os.Getenv("SSH_AUTH_SOCK") != "" {
+			return ssh.NewSSHAgentAuth(g.sshUser())
+		}
+		return nil, fmt.Errorf("ssh auth detected, but no key path configured")
+	}
+
+	key, err := ssh.NewPublicKeysFromFile(g.sshUser(), keyPath, conf.AuthKeyPassphrase)
+	if err != nil {
+		if conf.AuthKeyPassphrase == "" {
+			return nil, fmt.Errorf("error loading public key %v, if it's passphrase protected configure the passphrase: %w", keyPath, err)
+		}
+		return nil, fmt.Errorf("error loading public key %v: %w", keyPath, err)
+	}
+
+	return key, nil
+}
+
+// findSSHKey looks for a key to use for the host, first in any IdentityFile configured for it in the ssh config within
+// the directory, then the default key names. An empty path is returned if no key exists
+func findSSHKey(sshDir string, host string) (string, error) {
+	candidates := []string{}
+
+	if host != "" {
+		identities, err := identityFilesForHost(filepath.Join(sshDir, "config"), host, filepath.Dir(sshDir))
+		if err != nil {
+			return "", err
+		}
+		candidates = append(candidates, identities...)
+	}
+	for _, name := range defaultSSHKeyNames {
+		candidates = append(candidates, filepath.Join(sshDir, name))
+	}
+
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("error checking existence of %v: %w", candidate, err)
+		}
+	}
+
+	return "", nil
+}
+
+// identityFilesForHost returns every IdentityFile the ssh config at the given path sets for the host, with ~ expanded
+// to the given home directory
+func identityFilesForHost(path string, host string, home string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error opening ssh config: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	conf, err := ssh_config.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing ssh config: %w", err)
+	}
+
+	identities, err := conf.GetAll(host, "IdentityFile")
+	if err != nil {
+		return nil, fmt.Errorf("error reading IdentityFile for %v: %w", host, err)
+	}
+
+	for i, identity := range identities {
+		if identity == "~" || strings.HasPrefix(identity, "~/") {
+			identities[i] = filepath.Join(home, strings.TrimPrefix(identity, "~"))
+		}
+	}
+	return identities, nil
+}
+
+func (g *GitRepo) tokenAuth(conf GitRepoConfig) (*http.BasicAuth, error) {
+	// an app is only configured deliberately, so it wins over a token that may just be the workflow's GITHUB_TOKEN
+	if conf.GithubAppID != "" || conf.GithubAppInstallationID != "" {
+		token, err := githubAppToken(conf, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("error establishing github app auth: %w", err)
+		}
+		return &http.BasicAuth{
+			Username: githubAppTokenUsername,
+			Password: token,
+		}, nil
+	}
+
+	if conf.AuthToken != "" {
+		return &http.BasicAuth{
+			Username: conf.AuthTokenUsername,
+			Password: conf.AuthToken,
+		}, nil
+	}
+
+	// without a token, fall back to the credentials git itself would use, which is how anyone running locally against
+	// an https clone is already set up
+	if endpoint := g.pushEndpoint(); endpoint != nil {
+		if username, password, ok := credentialFill(conf.Path, endpoint); ok {
+			return &http.BasicAuth{
+				Username: username,
+				Password: password,
+			}, nil
+		}
+
+		path, err := netrcPath()
+		if err != nil {
+			return nil, err
+		}
+		username, password, ok, err := netrcCredentials(path, endpoint.Host)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return &http.BasicAuth{
+				Username: username,
+				Password: password,
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("token auth detected, but no token configured, and no credentials found from a git credential helper or .netrc")
+}
@@ -0,0 +1,363 @@
+package bot
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+	gogitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/lithammer/dedent"
+	"github.com/nicjohnson145/tagbot/internal/config"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestFindSSHKey(t *testing.T) {
+	newSSHDir := func(t *testing.T, files ...string) string {
+		t.Helper()
+
+		sshDir := filepath.Join(t.TempDir(), ".ssh")
+		require.NoError(t, os.MkdirAll(sshDir, 0700))
+		for _, f := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(sshDir, f), []byte("key"), 0600))
+		}
+		return sshDir
+	}
+
+	t.Run("no keys", func(t *testing.T) {
+		sshDir := newSSHDir(t)
+
+		got, err := findSSHKey(sshDir, "github.com")
+		require.NoError(t, err)
+		require.Equal(t, "", got)
+	})
+
+	t.Run("ed25519 default", func(t *testing.T) {
+		sshDir := newSSHDir(t, "id_ed25519")
+
+		got, err := findSSHKey(sshDir, "github.com")
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(sshDir, "id_ed25519"), got)
+	})
+
+	t.Run("defaults in ssh order", func(t *testing.T) {
+		sshDir := newSSHDir(t, "id_ed25519", "id_rsa")
+
+		got, err := findSSHKey(sshDir, "github.com")
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(sshDir, "id_rsa"), got)
+	})
+
+	t.Run("identity file for host", func(t *testing.T) {
+		sshDir := newSSHDir(t, "id_rsa", "work")
+		require.NoError(t, os.WriteFile(filepath.Join(sshDir, "config"), []byte(dedent.Dedent(`
+			Host github.com
+			    IdentityFile ~/.ssh/missing
+			    IdentityFile ~/.ssh/work
+
+			Host gitlab.com
+			    IdentityFile ~/.ssh/other
+		`)), 0600))
+
+		got, err := findSSHKey(sshDir, "github.com")
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(sshDir, "work"), got)
+
+		got, err = findSSHKey(sshDir, "example.com")
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(sshDir, "id_rsa"), got)
+	})
+}
+
+func TestSSHAuth(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := gossh.MarshalPrivateKeyWithPassphrase(private, "", []byte("hunter2"))
+	require.NoError(t, err)
+
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600))
+
+	g := &GitRepo{}
+
+	t.Run("passphrase", func(t *testing.T) {
+		_, err := g.sshAuth(GitRepoConfig{AuthKeyPath: keyPath, AuthKeyPassphrase: "hunter2"})
+		require.NoError(t, err)
+	})
+
+	t.Run("missing passphrase", func(t *testing.T) {
+		_, err := g.sshAuth(GitRepoConfig{AuthKeyPath: keyPath})
+		require.ErrorContains(t, err, "passphrase")
+	})
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		_, err := g.sshAuth(GitRepoConfig{AuthKeyPath: keyPath, AuthKeyPassphrase: "nope"})
+		require.Error(t, err)
+	})
+}
+
+func TestHostKeyCallback(t *testing.T) {
+	newHostKey := func(t *testing.T) gossh.PublicKey {
+		t.Helper()
+
+		public, _, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		key, err := gossh.NewPublicKey(public)
+		require.NoError(t, err)
+		return key
+	}
+
+	hostKey := newHostKey(t)
+	otherKey := newHostKey(t)
+	entry := knownhosts.Line([]string{"github.com"}, hostKey)
+	remote := &net.TCPAddr{IP: net.ParseIP("140.82.112.3"), Port: 22}
+
+	t.Run("nothing configured", func(t *testing.T) {
+		callback, err := hostKeyCallback(GitRepoConfig{})
+		require.NoError(t, err)
+		require.Nil(t, callback)
+	})
+
+	t.Run("entries", func(t *testing.T) {
+		callback, err := hostKeyCallback(GitRepoConfig{KnownHosts: entry})
+		require.NoError(t, err)
+
+		require.NoError(t, callback("github.com:22", remote, hostKey))
+
+		err = callback("github.com:22", remote, otherKey)
+		require.ErrorContains(t, explainHostKeyError(err), "does not match")
+
+		err = callback("gitlab.com:22", remote, hostKey)
+		require.ErrorContains(t, explainHostKeyError(err), "not in known hosts")
+	})
+
+	t.Run("path", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "known_hosts")
+		require.NoError(t, os.WriteFile(path, []byte(entry+"\n"), 0600))
+
+		callback, err := hostKeyCallback(GitRepoConfig{KnownHostsPath: path})
+		require.NoError(t, err)
+		require.NoError(t, callback("github.com:22", remote, hostKey))
+	})
+
+	t.Run("path and entries", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "known_hosts")
+		require.NoError(t, os.WriteFile(path, []byte(entry+"\n"), 0600))
+
+		callback, err := hostKeyCallback(GitRepoConfig{
+			KnownHostsPath: path,
+			KnownHosts:     knownhosts.Line([]string{"gitlab.com"}, otherKey),
+		})
+		require.NoError(t, err)
+		require.NoError(t, callback("github.com:22", remote, hostKey))
+		require.NoError(t, callback("gitlab.com:22", remote, otherKey))
+	})
+
+	t.Run("missing path", func(t *testing.T) {
+		_, err := hostKeyCallback(GitRepoConfig{KnownHostsPath: filepath.Join(t.TempDir(), "known_hosts")})
+		require.Error(t, err)
+	})
+
+	t.Run("insecure", func(t *testing.T) {
+		callback, err := hostKeyCallback(GitRepoConfig{KnownHosts: entry, InsecureIgnoreHostKey: true})
+		require.NoError(t, err)
+		require.NoError(t, callback("github.com:22", remote, otherKey))
+	})
+}
+
+func TestExplainHostKeyError(t *testing.T) {
+	unrelated := fmt.Errorf("connection refused")
+	require.Equal(t, unrelated, explainHostKeyError(unrelated))
+
+	// mirror how the ssh handshake wraps the callback's error
+	revoked := fmt.Errorf("ssh: handshake failed: %w", &knownhosts.RevokedError{})
+	require.ErrorContains(t, explainHostKeyError(revoked), "revoked")
+	require.ErrorIs(t, explainHostKeyError(revoked), revoked)
+}
+
+func TestAuthMethodFromURL(t *testing.T) {
+	testData := []struct {
+		name string
+		url  string
+		want config.AuthKind
+	}{
+		{name: "scp", url: "git@github.com:foo/bar.git", want: config.AuthKindPublicKey},
+		{name: "scp other user", url: "deploy@example.com:foo/bar.git", want: config.AuthKindPublicKey},
+		{name: "ssh with port", url: "ssh://git@example.com:2222/foo/bar.git", want: config.AuthKindPublicKey},
+		{name: "https", url: "https://github.com/foo/bar.git", want: config.AuthKindToken},
+		{name: "http", url: "http://gitea.local:3000/foo/bar.git", want: config.AuthKindToken},
+		{name: "file", url: "file:///srv/git/bar.git", want: config.AuthKindNone},
+		{name: "local path", url: "/srv/git/bar.git", want: config.AuthKindNone},
+	}
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := authMethodFromURL(tc.url)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestRemoteURL(t *testing.T) {
+	// keep the user's own git config out of it
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	newRepo := func(t *testing.T, gitConfig ...[]string) *GitRepo {
+		t.Helper()
+
+		dir := t.TempDir()
+		repo, err := gogit.PlainInit(dir, false)
+		require.NoError(t, err)
+		for _, kv := range gitConfig {
+			out, err := exec.Command("git", append([]string{"-C", dir, "config", "--add"}, kv...)...).CombinedOutput()
+			require.NoError(t, err, string(out))
+		}
+		// re-open, so the config written by git is read
+		repo, err = gogit.PlainOpen(dir)
+		require.NoError(t, err)
+		return &GitRepo{repo: repo}
+	}
+
+	t.Run("missing remote", func(t *testing.T) {
+		g := newRepo(t)
+		_, err := g.remoteURL(GitRepoConfig{Remote: "origin"})
+		require.ErrorContains(t, err, "unable to find remote")
+	})
+
+	t.Run("plain", func(t *testing.T) {
+		g := newRepo(t, []string{"remote.origin.url", "git@github.com:foo/bar.git"})
+		got, err := g.remoteURL(GitRepoConfig{Remote: "origin"})
+		require.NoError(t, err)
+		require.Equal(t, "git@github.com:foo/bar.git", got)
+	})
+
+	t.Run("created by go-git", func(t *testing.T) {
+		g := newRepo(t)
+		_, err := g.repo.CreateRemote(&gogitconfig.RemoteConfig{Name: "origin", URLs: []string{"https://github.com/foo/bar.git"}})
+		require.NoError(t, err)
+		got, err := g.remoteURL(GitRepoConfig{Remote: "origin"})
+		require.NoError(t, err)
+		require.Equal(t, "https://github.com/foo/bar.git", got)
+	})
+
+	t.Run("longest insteadOf", func(t *testing.T) {
+		g := newRepo(
+			t,
+			[]string{"remote.origin.url", "https://github.com/foo/bar.git"},
+			[]string{"url.git@github.com:.insteadOf", "https://github.com/"},
+			[]string{"url.ssh://git@mirror.local/.insteadOf", "https://github.com/foo/"},
+		)
+		got, err := g.remoteURL(GitRepoConfig{Remote: "origin"})
+		require.NoError(t, err)
+		require.Equal(t, "ssh://git@mirror.local/bar.git", got)
+	})
+
+	t.Run("pushInsteadOf preferred", func(t *testing.T) {
+		g := newRepo(
+			t,
+			[]string{"remote.origin.url", "https://github.com/foo/bar.git"},
+			[]string{"url.https://proxy.local/.insteadOf", "https://github.com/"},
+			[]string{"url.git@github.com:.pushInsteadOf", "https://github.com/"},
+		)
+		got, err := g.remoteURL(GitRepoConfig{Remote: "origin"})
+		require.NoError(t, err)
+		require.Equal(t, "git@github.com:foo/bar.git", got)
+	})
+
+	t.Run("pushurl", func(t *testing.T) {
+		g := newRepo(
+			t,
+			[]string{"remote.origin.url", "https://github.com/foo/bar.git"},
+			[]string{"remote.origin.pushurl", "https://github.com/foo/fork.git"},
+			[]string{"url.git@github.com:.insteadOf", "https://github.com/"},
+			[]string{"url.https://proxy.local/.pushInsteadOf", "https://github.com/"},
+		)
+		got, err := g.remoteURL(GitRepoConfig{Remote: "origin"})
+		require.NoError(t, err)
+		require.Equal(t, "git@github.com:foo/fork.git", got)
+	})
+
+	t.Run("user config", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		require.NoError(t, os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(dedent.Dedent(`
+			[url "git@github.com:"]
+				insteadOf = https://github.com/
+		`)), 0644))
+
+		g := newRepo(t, []string{"remote.origin.url", "https://github.com/foo/bar.git"})
+		got, err := g.remoteURL(GitRepoConfig{Remote: "origin"})
+		require.NoError(t, err)
+		require.Equal(t, "git@github.com:foo/bar.git", got)
+	})
+}
+
+func TestPushWithoutAuth(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	// pushes a tag from a new repo, whose origin is configured by the callback, to a bare repo it's given the path of
+	pushTag := func(t *testing.T, configure func(t *testing.T, dir string, bareDir string)) {
+		t.Helper()
+
+		bareDir := t.TempDir()
+		bare, err := gogit.PlainInit(bareDir, true)
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		src, err := gogit.PlainInit(dir, false)
+		require.NoError(t, err)
+		w, err := src.Worktree()
+		require.NoError(t, err)
+		createCommits(t, src, w.Filesystem, testCommit{Message: "feat: one", Files: []string{"foo"}})
+		configure(t, dir, bareDir)
+
+		g, err := NewGitRepo(GitRepoConfig{Path: dir, Remote: "origin"})
+		require.NoError(t, err)
+		require.Nil(t, g.auth)
+
+		ctx := newCtxWithLog(t)
+		created, err := g.MakeTagsAtTarget(ctx, "v1.0.0")
+		require.NoError(t, err)
+		_, err = g.PushTags(ctx, created)
+		require.NoError(t, err)
+
+		_, err = bare.Tag("v1.0.0")
+		require.NoError(t, err)
+	}
+
+	gitConfig := func(t *testing.T, dir string, kv ...string) {
+		t.Helper()
+
+		out, err := exec.Command("git", append([]string{"-C", dir, "config"}, kv...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	t.Run("file url", func(t *testing.T) {
+		pushTag(t, func(t *testing.T, dir string, bareDir string) {
+			gitConfig(t, dir, "remote.origin.url", "file://"+bareDir)
+		})
+	})
+
+	t.Run("local path", func(t *testing.T) {
+		pushTag(t, func(t *testing.T, dir string, bareDir string) {
+			gitConfig(t, dir, "remote.origin.url", bareDir)
+		})
+	})
+
+	t.Run("pushInsteadOf", func(t *testing.T) {
+		pushTag(t, func(t *testing.T, dir string, bareDir string) {
+			gitConfig(t, dir, "remote.origin.url", "https://example.com/foo/bar.git")
+			gitConfig(t, dir, "url.file://"+bareDir+".pushInsteadOf", "https://example.com/foo/bar.git")
+		})
+	})
+}
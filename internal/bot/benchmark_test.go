@@ -0,0 +1,326 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	commitgraphfmt "github.com/go-git/go-git/v5/plumbing/format/commitgraph/v2"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/nicjohnson145/hlp"
+	"github.com/nicjohnson145/tagbot/internal/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	benchCommits    = 2000
+	benchComponents = 50
+	benchPackages   = 5
+	benchFiles      = 4
+)
+
+// syntheticTree is an in memory directory, written to the object store only when it's changed
+type syntheticTree struct {
+	files map[string]plumbing.Hash
+	dirs  map[string]*syntheticTree
+	hash  plumbing.Hash
+	dirty bool
+}
+
+func newSyntheticTree() *syntheticTree {
+	return &syntheticTree{
+		files: map[string]plumbing.Hash{},
+		dirs:  map[string]*syntheticTree{},
+		dirty: true,
+	}
+}
+
+func (s *syntheticTree) set(parts []string, blob plumbing.Hash) {
+	s.dirty = true
+	if len(parts) == 1 {
+		s.files[parts[0]] = blob
+		return
+	}
+	child, ok := s.dirs[parts[0]]
+	if !ok {
+		child = newSyntheticTree()
+		s.dirs[parts[0]] = child
+	}
+	child.set(parts[1:], blob)
+}
+
+func (s *syntheticTree) write(st storer.EncodedObjectStorer) (plumbing.Hash, error) {
+	if !s.dirty {
+		return s.hash, nil
+	}
+
+	entries := []object.TreeEntry{}
+	for name, child := range s.dirs {
+		hash, err := child.write(st)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entries = append(entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash})
+	}
+	for name, hash := range s.files {
+		entries = append(entries, object.TreeEntry{Name: name, Mode: filemode.Regular, Hash: hash})
+	}
+	// git sorts directories as if they had a trailing slash
+	sortKey := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return sortKey(entries[i]) < sortKey(entries[j])
+	})
+
+	hash, err := writeObject(st, &object.Tree{Entries: entries})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	s.hash = hash
+	s.dirty = false
+	return hash, nil
+}
+
+func writeObject(st storer.EncodedObjectStorer, obj interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	encoded := st.NewEncodedObject()
+	if err := obj.Encode(encoded); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return st.SetEncodedObject(encoded)
+}
+
+func writeBlob(st storer.EncodedObjectStorer, content string) (plumbing.Hash, error) {
+	encoded := st.NewEncodedObject()
+	encoded.SetType(plumbing.BlobObject)
+	w, err := encoded.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return st.SetEncodedObject(encoded)
+}
+
+// newSyntheticRepo builds a linear history of benchCommits commits over benchComponents components, each commit
+// changing a single file. Objects are written directly, as going through a worktree is far too slow at this size. The
+// first component is only tagged on the very first commit, so any walk for it covers the entire history, while every
+// other component was tagged on its own commit near the tip. A commit-graph index of the history is returned alongside
+func newSyntheticRepo(b *testing.B) (*gogit.Repository, *commitgraphfmt.MemoryIndex) {
+	b.Helper()
+
+	st := memory.NewStorage()
+	repo, err := gogit.Init(st, nil)
+	require.NoError(b, err)
+
+	graph := commitgraphfmt.NewMemoryIndex()
+	root := newSyntheticTree()
+	for c := 0; c < benchComponents; c++ {
+		for p := 0; p < benchPackages; p++ {
+			for f := 0; f < benchFiles; f++ {
+				blob, err := writeBlob(st, fmt.Sprintf("%v-%v-%v", c, p, f))
+				require.NoError(b, err)
+				root.set(strings.Split(benchFilePath(c, p, f), "/"), blob)
+			}
+		}
+	}
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var parent plumbing.Hash
+	for i := 0; i < benchCommits; i++ {
+		component := (i * 7) % benchComponents
+		blob, err := writeBlob(st, fmt.Sprintf("commit %v", i))
+		require.NoError(b, err)
+		root.set(strings.Split(benchFilePath(component, i%benchPackages, i%benchFiles), "/"), blob)
+
+		treeHash, err := root.write(st)
+		require.NoError(b, err)
+
+		message := fmt.Sprintf("fix(comp-%03d): change %v", component, i)
+		if i%100 == 0 {
+			message = fmt.Sprintf("feat(comp-%03d): feature %v", component, i)
+		}
+		when := start.Add(time.Duration(i) * time.Minute)
+		commit := &object.Commit{
+			Author:    object.Signature{Name: "tagbot", Email: "tagbot@example.com", When: when},
+			Committer: object.Signature{Name: "tagbot", Email: "tagbot@example.com", When: when},
+			Message:   message,
+			TreeHash:  treeHash,
+		}
+		if i > 0 {
+			commit.ParentHashes = []plumbing.Hash{parent}
+		}
+		hash, err := writeObject(st, commit)
+		require.NoError(b, err)
+		graph.Add(hash, &commitgraphfmt.CommitData{
+			TreeHash:     treeHash,
+			ParentHashes: commit.ParentHashes,
+			Generation:   uint64(i + 1),
+			When:         when,
+		})
+		parent = hash
+
+		if i == 0 {
+			require.NoError(b, st.SetReference(plumbing.NewHashReference(plumbing.NewTagReferenceName("comp-000/v0.1.0"), hash)))
+		}
+		if c := benchCommits - 10 - i; c > 0 && c < benchComponents {
+			tag := plumbing.NewTagReferenceName(fmt.Sprintf("comp-%03d/v0.1.0", c))
+			require.NoError(b, st.SetReference(plumbing.NewHashReference(tag, hash)))
+		}
+	}
+	require.NoError(b, st.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("master"), parent)))
+
+	return repo, graph
+}
+
+func benchFilePath(component int, pkg int, file int) string {
+	return fmt.Sprintf("components/comp-%03d/src/pkg-%02d/file-%02d.go", component, pkg, file)
+}
+
+func benchMonorepoConfig() *config.MonoRepoConfig {
+	components := map[string]config.MonoRepoComponent{}
+	for c := 0; c < benchComponents; c++ {
+		name := fmt.Sprintf("comp-%03d", c)
+		components[name] = config.MonoRepoComponent{
+			Name:           name,
+			ChangeSetGlobs: []string{fmt.Sprintf("components/%v/**", name)},
+			MaintainLatest: hlp.Ptr(false),
+			LatestName:     hlp.Ptr("latest"),
+			NoV:            hlp.Ptr(false),
+			AlwaysPatch:    hlp.Ptr(false),
+		}
+	}
+	return &config.MonoRepoConfig{
+		Components: components,
+		ScopeMode:  config.ScopeModeScope,
+	}
+}
+
+func BenchmarkProcessLogWhere(b *testing.B) {
+	repo, _ := newSyntheticRepo(b)
+	ctx := zerolog.Nop().WithContext(context.Background())
+
+	walk := func(b *testing.B, opts LogOptions) {
+		for i := 0; i < b.N; i++ {
+			g := &GitRepo{repo: repo}
+			err := g.ProcessLogWhere(
+				ctx,
+				opts,
+				func(*object.Commit) bool { return false },
+				func(_ context.Context, _ *Commit) (bool, error) { return true, nil },
+			)
+			require.NoError(b, err)
+		}
+	}
+
+	b.Run("all paths", func(b *testing.B) {
+		walk(b, LogOptions{})
+	})
+
+	b.Run("pruned to one component", func(b *testing.B) {
+		walk(b, LogOptions{
+			PathGlobs: func() [][]string {
+				return [][]string{{"components/comp-000/**"}}
+			},
+		})
+	})
+}
+
+func BenchmarkRun(b *testing.B) {
+	repo, graph := newSyntheticRepo(b)
+	ctx := zerolog.Nop().WithContext(context.Background())
+
+	run := func(b *testing.B, newRepo func() *GitRepo) {
+		for i := 0; i < b.N; i++ {
+			bot := NewTagbot(TagbotConfig{
+				MonorepoConfig: benchMonorepoConfig(),
+				Repo:           newRepo(),
+				DryRun:         true,
+			})
+			require.NoError(b, bot.Run(ctx))
+		}
+	}
+
+	b.Run("commit objects", func(b *testing.B) {
+		run(b, func() *GitRepo {
+			return &GitRepo{repo: repo}
+		})
+	})
+
+	b.Run("commit-graph", func(b *testing.B) {
+		run(b, func() *GitRepo {
+			return &GitRepo{
+				repo:      repo,
+				nodeIndex: commitgraph.NewGraphCommitNodeIndex(graph, repo.Storer),
+			}
+		})
+	})
+}
+
+// BenchmarkIsAncestor asks what Run does of each component's latest tag: whether the commits since it, and the tagged
+// commit itself, are part of it. Every tag is on a different commit, so none of the walking is shared
+func BenchmarkIsAncestor(b *testing.B) {
+	repo, graph := newSyntheticRepo(b)
+	ctx := zerolog.Nop().WithContext(context.Background())
+
+	head, err := repo.Head()
+	require.NoError(b, err)
+	history := []string{}
+	iter, err := repo.Log(&gogit.LogOptions{From: head.Hash()})
+	require.NoError(b, err)
+	require.NoError(b, iter.ForEach(func(c *object.Commit) error {
+		history = append(history, c.Hash.String())
+		return nil
+	}))
+
+	ask := func(b *testing.B, newRepo func() *GitRepo) {
+		for i := 0; i < b.N; i++ {
+			g := newRepo()
+			for c := 1; c < benchComponents; c++ {
+				tag, err := repo.Tag(fmt.Sprintf("comp-%03d/v0.1.0", c))
+				require.NoError(b, err)
+				for _, hash := range history {
+					released, err := g.IsAncestor(ctx, hash, tag.Hash().String())
+					require.NoError(b, err)
+					if released {
+						break
+					}
+				}
+			}
+		}
+	}
+
+	b.Run("commit objects", func(b *testing.B) {
+		ask(b, func() *GitRepo {
+			return &GitRepo{repo: repo}
+		})
+	})
+
+	b.Run("commit-graph", func(b *testing.B) {
+		ask(b, func() *GitRepo {
+			return &GitRepo{
+				repo:      repo,
+				nodeIndex: commitgraph.NewGraphCommitNodeIndex(graph, repo.Storer),
+			}
+		})
+	})
+}
@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"strings"
+)
+
+// Values of git's `commit.cleanup` setting
+const (
+	CleanupModeDefault    = "default"
+	CleanupModeStrip      = "strip"
+	CleanupModeWhitespace = "whitespace"
+	CleanupModeVerbatim   = "verbatim"
+	CleanupModeScissors   = "scissors"
+
+	DefaultCommentChar = "#"
+)
+
+const scissorsLine = " ------------------------ >8 ------------------------"
+
+type CleanupConfig struct {
+	CommentChar string
+	Mode        string
+	// NoEditor is set when the message was never opened in an editor, such as with `git commit -m` or `-F`
+	NoEditor bool
+}
+
+// CleanupCommitMessage applies the same cleanup git does to a commit message before recording it, so the message is
+// validated as it will actually be committed
+func CleanupCommitMessage(message string, conf CleanupConfig) string {
+	mode := conf.Mode
+	if mode == "" || mode == CleanupModeDefault {
+		// git only strips comments when the message was edited, otherwise lines that look like comments are kept
+		mode = CleanupModeStrip
+		if conf.NoEditor {
+			mode = CleanupModeWhitespace
+		}
+	}
+	if mode == CleanupModeVerbatim {
+		return message
+	}
+
+	lines := strings.Split(message, "\n")
+
+	commentChar := conf.CommentChar
+	if commentChar == "" || commentChar == "auto" {
+		commentChar = autoCommentChar(lines)
+	}
+
+	// Everything below the scissors line (i.e the diff from `git commit -v`) is dropped
+	if mode == CleanupModeStrip || mode == CleanupModeScissors {
+		for i, line := range lines {
+			if line == commentChar+scissorsLine {
+				lines = lines[:i]
+				break
+			}
+		}
+	}
+
+	cleaned := []string{}
+	blankPending := false
+	for _, line := range lines {
+		if mode == CleanupModeStrip && strings.HasPrefix(line, commentChar) {
+			continue
+		}
+
+		line = strings.TrimRight(line, " \t\r\v\f")
+		if line == "" {
+			blankPending = len(cleaned) > 0
+			continue
+		}
+
+		// collapse runs of blank lines into one, dropping any leading ones
+		if blankPending {
+			cleaned = append(cleaned, "")
+			blankPending = false
+		}
+		cleaned = append(cleaned, line)
+	}
+
+	if len(cleaned) == 0 {
+		return ""
+	}
+	return strings.Join(cleaned, "\n") + "\n"
+}
+
+// autoCommentChar guesses the comment character git picked when `core.commentChar` is `auto`, preferring the one used
+// by a scissors line if present
+func autoCommentChar(lines []string) string {
+	for _, line := range lines {
+		if before, ok := strings.CutSuffix(line, scissorsLine); ok && len(before) == 1 {
+			return before
+		}
+	}
+	return DefaultCommentChar
+}
@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanupCommitMessage(t *testing.T) {
+	t.Parallel()
+
+	verbose := dedent.Dedent(`
+		# Please enter the commit message for your changes.
+
+		feat: do a thing  
+
+		# On branch main
+
+
+		with a body
+		# ------------------------ >8 ------------------------
+		# Do not modify or remove the line above.
+		diff --git a/foo b/foo
+		+added line
+	`[1:])
+
+	testData := []struct {
+		name     string
+		message  string
+		config   CleanupConfig
+		expected string
+	}{
+		{
+			name:     "default strips comments and diff",
+			message:  verbose,
+			config:   CleanupConfig{},
+			expected: "feat: do a thing\n\nwith a body\n",
+		},
+		{
+			name:     "default without an editor keeps comments",
+			message:  "feat: do a thing  \n\n# not a comment\n",
+			config:   CleanupConfig{NoEditor: true},
+			expected: "feat: do a thing\n\n# not a comment\n",
+		},
+		{
+			name:     "strip without an editor",
+			message:  "feat: do a thing\n\n# a comment\n",
+			config:   CleanupConfig{Mode: CleanupModeStrip, NoEditor: true},
+			expected: "feat: do a thing\n",
+		},
+		{
+			name:     "scissors keeps comments",
+			message:  verbose,
+			config:   CleanupConfig{Mode: CleanupModeScissors},
+			expected: "# Please enter the commit message for your changes.\n\nfeat: do a thing\n\n# On branch main\n\nwith a body\n",
+		},
+		{
+			name:     "whitespace",
+			message:  "\n\nfeat: do a thing\n# not a comment\n\n\n",
+			config:   CleanupConfig{Mode: CleanupModeWhitespace},
+			expected: "feat: do a thing\n# not a comment\n",
+		},
+		{
+			name:     "verbatim",
+			message:  verbose,
+			config:   CleanupConfig{Mode: CleanupModeVerbatim},
+			expected: verbose,
+		},
+		{
+			name: "custom comment char",
+			message: dedent.Dedent(`
+				; a comment
+				feat: do a thing
+				#not a comment
+				; ------------------------ >8 ------------------------
+				diff
+			`[1:]),
+			config:   CleanupConfig{CommentChar: ";"},
+			expected: "feat: do a thing\n#not a comment\n",
+		},
+		{
+			name: "auto comment char",
+			message: dedent.Dedent(`
+				feat: do a thing
+				% a comment
+				% ------------------------ >8 ------------------------
+				diff
+			`[1:]),
+			config:   CleanupConfig{CommentChar: "auto"},
+			expected: "feat: do a thing\n",
+		},
+		{
+			name:     "only comments",
+			message:  "# nothing here\n",
+			config:   CleanupConfig{Mode: CleanupModeStrip},
+			expected: "",
+		},
+	}
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.expected, CleanupCommitMessage(tc.message, tc.config))
+		})
+	}
+}
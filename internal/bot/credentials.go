@@ -0,0 +1,156 @@
+package bot
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// credentialFill asks git for the credentials it would use for the endpoint, via `git credential fill` ran within the
+// repo at the given path so its configured credential.helper is used. Anything going wrong, including git not being
+// installed or no helper having credentials, is treated as having none
+func credentialFill(path string, endpoint *transport.Endpoint) (string, string, bool) {
+	host := endpoint.Host
+	if endpoint.Port != 0 {
+		host = fmt.Sprintf("%v:%v", host, endpoint.Port)
+	}
+
+	input := &strings.Builder{}
+	fmt.Fprintf(input, "protocol=%v\n", endpoint.Protocol)
+	fmt.Fprintf(input, "host=%v\n", host)
+	if p := strings.TrimPrefix(endpoint.Path, "/"); p != "" {
+		fmt.Fprintf(input, "path=%v\n", p)
+	}
+	if endpoint.User != "" {
+		fmt.Fprintf(input, "username=%v\n", endpoint.User)
+	}
+	input.WriteString("\n")
+
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Dir = path
+	cmd.Stdin = strings.NewReader(input.String())
+	// never fall back to prompting, there's nobody to answer
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", false
+	}
+
+	var username, password string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), "=")
+		switch key {
+		case "username":
+			username = value
+		case "password":
+			password = value
+		}
+	}
+
+	if password == "" {
+		return "", "", false
+	}
+	return username, password, true
+}
+
+// netrcPath is the file named by $NETRC, otherwise ~/.netrc
+func netrcPath() (string, error) {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting user home directory: %w", err)
+	}
+	return filepath.Join(home, ".netrc"), nil
+}
+
+// netrcCredentials returns the login and password of the first entry in the netrc file at the given path for the host,
+// or its default entry if none match. A missing file has no credentials
+func netrcCredentials(path string, host string) (string, string, bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", "", false, nil
+		}
+		return "", "", false, fmt.Errorf("error reading netrc: %w", err)
+	}
+
+	// macro definitions run until the next blank line, and can contain anything, so drop them before tokenizing
+	tokens := []string{}
+	inMacro := false
+	for _, line := range strings.Split(string(content), "\n") {
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		fields := strings.Fields(line)
+		tokens = append(tokens, fields...)
+		if len(fields) > 0 && fields[0] == "macdef" {
+			inMacro = true
+		}
+	}
+
+	type netrcEntry struct {
+		login    string
+		password string
+	}
+	var (
+		matched      *netrcEntry
+		defaultEntry *netrcEntry
+		current      *netrcEntry
+	)
+	for i := 0; i < len(tokens); i++ {
+		value := ""
+		if i+1 < len(tokens) {
+			value = tokens[i+1]
+		}
+
+		switch tokens[i] {
+		case "machine":
+			current = nil
+			if value == host && matched == nil {
+				matched = &netrcEntry{}
+				current = matched
+			}
+			i++
+		case "default":
+			current = nil
+			if defaultEntry == nil {
+				defaultEntry = &netrcEntry{}
+				current = defaultEntry
+			}
+		case "login":
+			if current != nil {
+				current.login = value
+			}
+			i++
+		case "password":
+			if current != nil {
+				current.password = value
+			}
+			i++
+		case "account", "macdef":
+			i++
+		}
+	}
+
+	entry := matched
+	if entry == nil {
+		entry = defaultEntry
+	}
+	if entry == nil || entry.password == "" {
+		return "", "", false, nil
+	}
+	return entry.login, entry.password, true, nil
+}
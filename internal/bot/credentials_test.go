@@ -0,0 +1,141 @@
+package bot
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/lithammer/dedent"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentialFill(t *testing.T) {
+	// keep the user's own git config, and so their credential helpers, out of it
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	endpoint, err := transport.NewEndpoint("https://example.com/foo/bar.git")
+	require.NoError(t, err)
+
+	newRepoDir := func(t *testing.T, helper string) string {
+		t.Helper()
+
+		dir := t.TempDir()
+		out, err := exec.Command("git", "init", dir).CombinedOutput()
+		require.NoError(t, err, string(out))
+		if helper != "" {
+			out, err = exec.Command("git", "-C", dir, "config", "credential.helper", helper).CombinedOutput()
+			require.NoError(t, err, string(out))
+		}
+		return dir
+	}
+
+	t.Run("helper", func(t *testing.T) {
+		dir := newRepoDir(t, "!f() { test \"$1\" = get && echo username=someone && echo password=hunter2; }; f")
+
+		username, password, ok := credentialFill(dir, endpoint)
+		require.True(t, ok)
+		require.Equal(t, "someone", username)
+		require.Equal(t, "hunter2", password)
+	})
+
+	t.Run("no helper", func(t *testing.T) {
+		dir := newRepoDir(t, "")
+
+		_, _, ok := credentialFill(dir, endpoint)
+		require.False(t, ok)
+	})
+}
+
+func TestNetrcCredentials(t *testing.T) {
+	writeNetrc := func(t *testing.T, content string) string {
+		t.Helper()
+
+		path := filepath.Join(t.TempDir(), ".netrc")
+		require.NoError(t, os.WriteFile(path, []byte(dedent.Dedent(content)), 0600))
+		return path
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, _, ok, err := netrcCredentials(filepath.Join(t.TempDir(), ".netrc"), "example.com")
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("machine", func(t *testing.T) {
+		path := writeNetrc(t, `
+			machine other.com login nope password nope
+			machine example.com
+			    login someone
+			    password hunter2
+		`)
+
+		login, password, ok, err := netrcCredentials(path, "example.com")
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, "someone", login)
+		require.Equal(t, "hunter2", password)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		path := writeNetrc(t, `
+			machine other.com login nope password nope
+
+			macdef init
+			password ignored
+			machine example.com
+
+			default login fallback password hunter2
+		`)
+
+		login, password, ok, err := netrcCredentials(path, "example.com")
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, "fallback", login)
+		require.Equal(t, "hunter2", password)
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		path := writeNetrc(t, `
+			machine other.com login nope password nope
+		`)
+
+		_, _, ok, err := netrcCredentials(path, "example.com")
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+}
+
+func TestTokenAuthFallbacks(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("NETRC", "")
+
+	g := &GitRepo{pushURL: "https://example.com/foo/bar.git"}
+
+	t.Run("token preferred", func(t *testing.T) {
+		auth, err := g.tokenAuth(GitRepoConfig{AuthToken: "some-token", AuthTokenUsername: "TagBot"})
+		require.NoError(t, err)
+		require.Equal(t, "some-token", auth.Password)
+	})
+
+	t.Run("nothing found", func(t *testing.T) {
+		_, err := g.tokenAuth(GitRepoConfig{Path: t.TempDir()})
+		require.ErrorContains(t, err, "no token configured")
+	})
+
+	t.Run("netrc", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(home, ".netrc"), []byte("machine example.com login someone password hunter2\n"), 0600))
+		t.Cleanup(func() {
+			_ = os.Remove(filepath.Join(home, ".netrc"))
+		})
+
+		auth, err := g.tokenAuth(GitRepoConfig{Path: t.TempDir()})
+		require.NoError(t, err)
+		require.Equal(t, "someone", auth.Username)
+		require.Equal(t, "hunter2", auth.Password)
+	})
+}
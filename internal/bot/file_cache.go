@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// fileCacheVersion is bumped whenever the format of cache entries, or what's stored in them, changes. Entries written
+// with any other version are ignored and overwritten
+const fileCacheVersion = 1
+
+// fileCache is an on-disk cache of the files each commit changed relative to its first parent. Commits are immutable,
+// so entries never go stale, and each entry is its own file written atomically, so the cache can be shared by
+// concurrent runs or saved and restored between CI jobs
+type fileCache struct {
+	dir string
+}
+
+type fileCacheEntry struct {
+	Version int      `json:"version"`
+	Files   []string `json:"files"`
+}
+
+func newFileCache(dir string) (*fileCache, error) {
+	dir = filepath.Join(dir, "files")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating file cache directory: %w", err)
+	}
+	return &fileCache{dir: dir}, nil
+}
+
+// path shards entries by the first byte of the hash, the same way git stores loose objects
+func (f *fileCache) path(hash plumbing.Hash) string {
+	h := hash.String()
+	return filepath.Join(f.dir, h[:2], h[2:]+".json")
+}
+
+// Get returns the cached files for the commit. Anything unreadable is treated as a miss, as it'll just be recomputed
+func (f *fileCache) Get(hash plumbing.Hash) ([]string, bool) {
+	content, err := os.ReadFile(f.path(hash))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry fileCacheEntry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, false
+	}
+	if entry.Version != fileCacheVersion || entry.Files == nil {
+		return nil, false
+	}
+	return entry.Files, true
+}
+
+func (f *fileCache) Put(hash plumbing.Hash, files []string) error {
+	content, err := json.Marshal(fileCacheEntry{
+		Version: fileCacheVersion,
+		Files:   files,
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding cache entry: %w", err)
+	}
+
+	path := f.path(hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating cache directory: %w", err)
+	}
+
+	// write to a temporary file and move it into place, so readers never see a partial entry
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary cache entry: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("error writing cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error moving cache entry into place: %w", err)
+	}
+
+	return nil
+}
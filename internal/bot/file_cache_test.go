@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCache(t *testing.T) {
+	hash := plumbing.NewHash("0123456789abcdef0123456789abcdef01234567")
+
+	t.Run("round trip", func(t *testing.T) {
+		cache, err := newFileCache(t.TempDir())
+		require.NoError(t, err)
+
+		_, ok := cache.Get(hash)
+		require.False(t, ok)
+
+		require.NoError(t, cache.Put(hash, []string{"foo", "bar/baz"}))
+		got, ok := cache.Get(hash)
+		require.True(t, ok)
+		require.Equal(t, []string{"foo", "bar/baz"}, got)
+	})
+
+	t.Run("commits changing nothing are cached", func(t *testing.T) {
+		cache, err := newFileCache(t.TempDir())
+		require.NoError(t, err)
+
+		require.NoError(t, cache.Put(hash, []string{}))
+		got, ok := cache.Get(hash)
+		require.True(t, ok)
+		require.Empty(t, got)
+	})
+
+	t.Run("other versions are ignored", func(t *testing.T) {
+		cache, err := newFileCache(t.TempDir())
+		require.NoError(t, err)
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(cache.path(hash)), 0755))
+		require.NoError(t, os.WriteFile(cache.path(hash), []byte(`{"version":0,"files":["foo"]}`), 0644))
+		_, ok := cache.Get(hash)
+		require.False(t, ok)
+
+		require.NoError(t, os.WriteFile(cache.path(hash), []byte(`not json`), 0644))
+		_, ok = cache.Get(hash)
+		require.False(t, ok)
+	})
+
+	t.Run("used when walking", func(t *testing.T) {
+		repo, g := newMemoryGitRepo(
+			t,
+			testCommit{Message: "feat: initial", Files: []string{"foo/a", "bar/a"}},
+			testCommit{Message: "feat: more", Files: []string{"foo/b"}},
+		)
+		head, err := repo.repo.Head()
+		require.NoError(t, err)
+
+		cache, err := newFileCache(t.TempDir())
+		require.NoError(t, err)
+		g.fileCache = cache
+
+		commit, err := repo.repo.CommitObject(head.Hash())
+		require.NoError(t, err)
+
+		// a miss computes and stores every changed file, but only returns those passing the filter
+		got, err := g.cachedFilesForCommit(newCtxWithLog(t), commit, newPathFilter([][]string{{"bar/**"}}, true))
+		require.NoError(t, err)
+		require.Empty(t, got)
+		cached, ok := cache.Get(head.Hash())
+		require.True(t, ok)
+		require.Equal(t, []string{"foo/b"}, cached)
+
+		// a hit is served from the cache without diffing
+		require.NoError(t, cache.Put(head.Hash(), []string{"bar/from-cache"}))
+		got, err = g.cachedFilesForCommit(newCtxWithLog(t), commit, newPathFilter([][]string{{"bar/**"}}, true))
+		require.NoError(t, err)
+		require.Equal(t, []string{"bar/from-cache"}, got)
+	})
+}
@@ -1,10 +1,11 @@
 package bot
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 	"time"
@@ -13,34 +14,99 @@ import (
 	gogit "github.com/go-git/go-git/v5"
 	gogitconfig "github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	commitgraphfmt "github.com/go-git/go-git/v5/plumbing/format/commitgraph/v2"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
 	"github.com/go-git/go-git/v5/plumbing/transport"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
-	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
-	"github.com/go-git/go-git/v5/utils/merkletrie"
-	"github.com/go-git/go-git/v5/utils/merkletrie/noder"
+	"github.com/go-git/go-git/v5/storage/filesystem"
 	"github.com/nicjohnson145/tagbot/internal/config"
 	"github.com/rs/zerolog"
 )
 
+const (
+	initialDeepenDepth = 50
+	maxDeepenDepth     = 10000
+	// the same depth `git fetch --unshallow` asks for
+	unshallowDepth = 2147483647
+)
+
+var (
+	ErrShallowRepository = errors.New("repository is a shallow clone")
+	ErrBranchNotAllowed  = errors.New("branch not allowed")
+	ErrDirtyWorktree     = errors.New("worktree has uncommitted changes")
+	ErrNotPushed         = errors.New("commit has not been pushed")
+)
+
 type GitRepoConfig struct {
-	Path              string
-	Remote            string
-	AuthMethod        string
-	AuthKeyPath       string
-	AuthToken         string
-	AuthTokenUsername string
+	Path                    string
+	Ref                     string
+	NoPush                  bool
+	UseCommitGraph          bool
+	FileCache               bool
+	FileCachePath           string
+	Remote                  string
+	AuthMethod              string
+	AuthKeyPath             string
+	AuthKeyPassphrase       string
+	AuthToken               string
+	AuthTokenUsername       string
+	GithubAppID             string
+	GithubAppInstallationID string
+	GithubAppPrivateKeyPath string
+	GithubAppPrivateKey     string
+	GithubAPIURL            string
+	KnownHostsPath          string
+	KnownHosts              string
+	InsecureIgnoreHostKey   bool
+	ShallowMode             config.ShallowKind
+	RequireCleanWorktree    bool
+	RequirePushed           bool
 }
 
 func NewGitRepo(conf GitRepoConfig) (*GitRepo, error) {
-	repo, err := gogit.PlainOpen(conf.Path)
+	// Open the path directly first, as that's the only way bare repos are found, then search upwards for the repo so
+	// tagbot can be ran from any subdirectory, including those of linked worktrees
+	opts := &gogit.PlainOpenOptions{
+		EnableDotGitCommonDir: true,
+	}
+	repo, err := gogit.PlainOpenWithOptions(conf.Path, opts)
+	if errors.Is(err, gogit.ErrRepositoryNotExists) {
+		opts.DetectDotGit = true
+		repo, err = gogit.PlainOpenWithOptions(conf.Path, opts)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error opening git repo: %w", err)
 	}
 
 	gr := &GitRepo{
-		remote: conf.Remote,
-		repo:   repo,
+		ref:            conf.Ref,
+		useCommitGraph: conf.UseCommitGraph,
+		remote:         conf.Remote,
+		shallowMode:    conf.ShallowMode,
+		repo:           repo,
+
+		// an explicitly named commit doesn't come from the worktree, so its state doesn't matter
+		requireCleanWorktree: conf.RequireCleanWorktree && conf.Ref == "",
+		// without pushing, nothing is published that others would need to have
+		requirePushed: conf.RequirePushed && !conf.NoPush,
+		pushes:        !conf.NoPush,
+	}
+
+	// Fail fast on a ref that doesn't exist
+	if _, err := gr.targetHash(); err != nil {
+		return nil, err
+	}
+
+	if conf.FileCache {
+		if err := gr.initializeFileCache(conf); err != nil {
+			return nil, fmt.Errorf("error initializing file cache: %w", err)
+		}
+	}
+
+	// Nothing will be pushed, so there's no remote to authenticate against (and likely no remote at all, such as when
+	// running in a bare repo on the git server itself)
+	if conf.NoPush {
+		return gr, nil
 	}
 
 	if err := gr.initializeAuth(conf); err != nil {
@@ -53,114 +119,303 @@ func NewGitRepo(conf GitRepoConfig) (*GitRepo, error) {
 var _ IRepo = (*GitRepo)(nil)
 
 type GitRepo struct {
-	remote string
+	ref            string
+	useCommitGraph bool
+	remote         string
+	pushURL        string
+	shallowMode    config.ShallowKind
+
+	requireCleanWorktree bool
+	requirePushed        bool
+	// tags are pushed to the remote, so what's released there counts as much as what's released locally
+	pushes bool
 
 	repo *gogit.Repository
 	auth transport.AuthMethod
 
-	tagsByPrefix map[string][]Tag
+	tagsByPrefix       map[string][]Tag
+	remoteTagsAtTarget map[string][]Tag
+	ancestryWalkers    map[string]*ancestryWalker
+	nodeParents        parentCache
+	nodeIndex          commitgraph.CommitNodeIndex
+	fileCache          *fileCache
 }
 
-func (g *GitRepo) initializeAuth(conf GitRepoConfig) error {
-	var authMethod config.AuthKind
+func (g *GitRepo) initializeFileCache(conf GitRepoConfig) error {
+	dir := conf.FileCachePath
+	if dir == "" {
+		// default to living alongside the repo's own data, so it's never committed and goes away with the clone
+		fsStorage, ok := g.repo.Storer.(*filesystem.Storage)
+		if !ok {
+			return fmt.Errorf("repository isn't stored on disk, a file cache path must be configured")
+		}
+		dir = filepath.Join(fsStorage.Filesystem().Root(), "tagbot")
+	}
 
-	// if our auth method is explicitly configured, then use that
-	if conf.AuthMethod != "" {
-		meth, err := config.ParseAuthKind(conf.AuthMethod)
+	cache, err := newFileCache(dir)
+	if err != nil {
+		return err
+	}
+	g.fileCache = cache
+	return nil
+}
+
+// EnsureHistory makes sure enough history is present to find the latest tag of each prefix, deepening shallow clones if
+// configured to do so
+func (g *GitRepo) EnsureHistory(ctx context.Context, prefixes []string) error {
+	log := zerolog.Ctx(ctx)
+
+	shallow, err := g.isShallow()
+	if err != nil {
+		return fmt.Errorf("error checking for shallow clone: %w", err)
+	}
+	if !shallow {
+		return nil
+	}
+
+	// a clone deep enough to hold the latest tags (i.e `fetch-depth: N` with tags) doesn't need anything more
+	reachable, err := g.latestTagsReachable(ctx, prefixes)
+	if err != nil {
+		return err
+	}
+	if reachable {
+		return nil
+	}
+
+	if g.shallowMode != config.ShallowKindDeepen {
+		return fmt.Errorf(
+			"%w: tagbot needs the history back to the latest tag. Run `git fetch --unshallow --tags`, set `fetch-depth: 0` on actions/checkout, or set --%v=%v",
+			ErrShallowRepository,
+			config.ShallowMode,
+			config.ShallowKindDeepen,
+		)
+	}
+
+	for depth := initialDeepenDepth; ; depth *= 2 {
+		if depth >= maxDeepenDepth {
+			depth = unshallowDepth
+		}
+
+		log.Info().Msgf("shallow clone detected, fetching history to depth %v", depth)
+		err := g.repo.FetchContext(ctx, &gogit.FetchOptions{
+			RemoteName: g.remote,
+			Depth:      depth,
+			Tags:       gogit.AllTags,
+			Auth:       g.auth,
+		})
+		if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+			return fmt.Errorf("error deepening history: %w", explainHostKeyError(err))
+		}
+
+		// anything cached was computed against the old history
+		g.tagsByPrefix = nil
+		g.ancestryWalkers = nil
+		g.nodeIndex = nil
+
+		if depth == unshallowDepth {
+			return nil
+		}
+
+		reachable, err := g.latestTagsReachable(ctx, prefixes)
 		if err != nil {
 			return err
 		}
-		authMethod = meth
-	} else { // otherwise try and detect it from the configured origin of the repo
-		meth, err := g.authMethodFromRemote(conf)
-		if err != nil {
-			return fmt.Errorf("error detecting auth method: %w", err)
+		if reachable {
+			return nil
 		}
-		authMethod = meth
+	}
+}
+
+// isShallow checks if the history ends anywhere but at a root commit
+func (g *GitRepo) isShallow() (bool, error) {
+	boundary, err := g.shallowBoundary()
+	if err != nil {
+		return false, err
+	}
+	return len(boundary) > 0, nil
+}
+
+// shallowBoundary returns the shallow commits that are missing their parents, i.e where the history of a shallow clone
+// ends. Deepening a clone doesn't remove commits from the shallow list, so being on the list isn't enough on its own
+func (g *GitRepo) shallowBoundary() (map[plumbing.Hash]bool, error) {
+	shallows, err := g.repo.Storer.Shallow()
+	if err != nil {
+		return nil, err
 	}
 
-	switch authMethod {
-	case config.AuthKindPublicKey:
-		a, err := g.sshAuth(conf)
+	boundary := map[plumbing.Hash]bool{}
+	for _, hash := range shallows {
+		commit, err := g.repo.CommitObject(hash)
 		if err != nil {
-			return fmt.Errorf("error establishing ssh auth: %w", err)
+			return nil, fmt.Errorf("error getting shallow commit %v: %w", hash, err)
 		}
-		g.auth = a
-	case config.AuthKindToken:
-		a, err := g.tokenAuth(conf)
-		if err != nil {
-			return fmt.Errorf("error establishing token auth: %w", err)
+		for _, parent := range commit.ParentHashes {
+			if err := g.repo.Storer.HasEncodedObject(parent); err != nil {
+				boundary[hash] = true
+				break
+			}
 		}
-		g.auth = a
-	default:
-		return fmt.Errorf("unhandled auth kind %v", authMethod)
 	}
 
-	return nil
+	return boundary, nil
 }
 
-func (g *GitRepo) authMethodFromRemote(conf GitRepoConfig) (config.AuthKind, error) {
-	remotes, err := g.repo.Remotes()
-	if err != nil {
-		return config.AuthKind(""), fmt.Errorf("error listing remotes: %w", err)
+// latestTagsReachable checks if the latest tag of each prefix can be determined without running off the edge of a
+// shallow clone. A prefix without any tag isn't, as the missing history (or tags that weren't fetched) may hold one
+func (g *GitRepo) latestTagsReachable(ctx context.Context, prefixes []string) (bool, error) {
+	for _, prefix := range prefixes {
+		tag, err := g.GetLatestTag(ctx, prefix)
+		if err != nil {
+			if errors.Is(err, plumbing.ErrObjectNotFound) {
+				return false, nil
+			}
+			return false, err
+		}
+		if tag == nil {
+			return false, nil
+		}
 	}
+	return true, nil
+}
 
-	idx := slices.IndexFunc(remotes, func(r *gogit.Remote) bool {
-		return r.Config().Name == conf.Remote
-	})
-	if idx == -1 {
-		return config.AuthKind(""), fmt.Errorf("unable to find remote named '%v'", conf.Remote)
+func (g *GitRepo) GetLatestTag(ctx context.Context, prefix string) (*Tag, error) {
+	if g.tagsByPrefix == nil {
+		zerolog.Ctx(ctx).Debug().Msg("tag map is nil, populating tag cache")
+		if err := g.constructTagsByPrefixMap(ctx); err != nil {
+			return nil, fmt.Errorf("error constructing tag map: %w", err)
+		}
 	}
 
-	url := remotes[idx].Config().URLs[0]
-	if strings.HasPrefix(url, "git@") {
-		return config.AuthKindPublicKey, nil
+	tagList, ok := g.tagsByPrefix[prefix]
+	if !ok {
+		return nil, nil
+	}
+
+	target, err := g.targetHash()
+	if err != nil {
+		return nil, err
 	}
-	if strings.HasPrefix(url, "https://") {
-		return config.AuthKindToken, nil
+
+	// Much like `git describe`, the latest tag is the highest one that is actually part of the history being tagged.
+	// Tags on unmerged branches, or newer releases when on an older maintenance branch, are not
+	log := zerolog.Ctx(ctx)
+	for i := range tagList {
+		tag := &tagList[i]
+		reachable, err := g.IsAncestor(ctx, tag.Hash, target.String())
+		if err != nil {
+			return nil, fmt.Errorf("error checking if %v is reachable: %w", tag.TagName, err)
+		}
+		if reachable {
+			return tag, nil
+		}
+		log.Warn().Msgf("tag %v is not reachable from %v, ignoring it", joinPrefix(prefix, tag.TagName), g.targetName())
 	}
 
-	return config.AuthKind(""), fmt.Errorf("unable to auto determine auth method, please explictly configure")
+	return nil, nil
 }
 
-func (g *GitRepo) sshAuth(conf GitRepoConfig) (*ssh.PublicKeys, error) {
-	if conf.AuthKeyPath == "" {
-		return nil, fmt.Errorf("ssh auth detected, but no key path configured")
+// GetTagsAtTarget returns the semver tags with the prefix that point directly at the commit being tagged, highest first.
+// When pushing, the remote's tags are included too, as another run may have released the commit without those tags
+// having been fetched
+func (g *GitRepo) GetTagsAtTarget(ctx context.Context, prefix string) ([]Tag, error) {
+	if g.tagsByPrefix == nil {
+		if err := g.constructTagsByPrefixMap(ctx); err != nil {
+			return nil, fmt.Errorf("error constructing tag map: %w", err)
+		}
 	}
 
-	key, err := ssh.NewPublicKeysFromFile("git", conf.AuthKeyPath, "")
+	target, err := g.targetHash()
 	if err != nil {
-		return nil, fmt.Errorf("error loading public key: %w", err)
+		return nil, err
 	}
 
-	return key, nil
+	tags := []Tag{}
+	for _, tag := range g.tagsByPrefix[prefix] {
+		if tag.Hash == target.String() {
+			tags = append(tags, tag)
+		}
+	}
+
+	if g.pushes {
+		if g.remoteTagsAtTarget == nil {
+			if err := g.listRemoteTagsAtTarget(ctx, target); err != nil {
+				return nil, err
+			}
+		}
+		for _, tag := range g.remoteTagsAtTarget[prefix] {
+			if !slices.ContainsFunc(tags, func(t Tag) bool { return t.TagName == tag.TagName }) {
+				tags = append(tags, tag)
+			}
+		}
+		slices.SortFunc(tags, func(a Tag, b Tag) int {
+			return -1 * a.Tag.Compare(b.Tag)
+		})
+	}
+
+	return tags, nil
 }
 
-func (g *GitRepo) tokenAuth(conf GitRepoConfig) (*http.BasicAuth, error) {
-	if conf.AuthToken == "" {
-		return nil, fmt.Errorf("token auth detected, but no token configured")
+// listRemoteTagsAtTarget caches the remote's semver tags that point directly at the commit being tagged, by prefix
+func (g *GitRepo) listRemoteTagsAtTarget(ctx context.Context, target plumbing.Hash) error {
+	url, err := g.pushedURL()
+	if err != nil {
+		return err
+	}
+	remote := gogit.NewRemote(g.repo.Storer, &gogitconfig.RemoteConfig{
+		Name: g.remote,
+		URLs: []string{url},
+	})
+	refs, err := remote.ListContext(ctx, &gogit.ListOptions{
+		Auth:          g.auth,
+		PeelingOption: gogit.AppendPeeled,
+	})
+	if err != nil {
+		return fmt.Errorf("error listing tags of %v: %w", g.remote, explainHostKeyError(err))
 	}
 
-	return &http.BasicAuth{
-		Username: conf.AuthTokenUsername,
-		Password: conf.AuthToken,
-	}, nil
+	// annotated tags are advertised at the tag object, followed by the commit it peels to
+	targets := map[plumbing.ReferenceName]plumbing.Hash{}
+	for _, ref := range refs {
+		name, peeled := strings.CutSuffix(ref.Name().String(), "^{}")
+		if _, ok := targets[plumbing.ReferenceName(name)]; !ok || peeled {
+			targets[plumbing.ReferenceName(name)] = ref.Hash()
+		}
+	}
+
+	g.remoteTagsAtTarget = map[string][]Tag{}
+	for name, hash := range targets {
+		if !name.IsTag() || hash != target {
+			continue
+		}
+		prefix, tagName, ver, ok := splitTagName(name.Short())
+		if !ok {
+			continue
+		}
+		g.remoteTagsAtTarget[prefix] = append(g.remoteTagsAtTarget[prefix], Tag{
+			TagName: tagName,
+			Tag:     ver,
+			Hash:    hash.String(),
+		})
+	}
+	return nil
 }
 
-func (g *GitRepo) GetLatestTag(ctx context.Context, prefix string) (*Tag, error) {
+// GetTags returns every semver tag with the prefix, reachable or not, highest first
+func (g *GitRepo) GetTags(ctx context.Context, prefix string) ([]Tag, error) {
 	if g.tagsByPrefix == nil {
-		zerolog.Ctx(ctx).Debug().Msg("tag map is nil, populating tag cache")
 		if err := g.constructTagsByPrefixMap(ctx); err != nil {
 			return nil, fmt.Errorf("error constructing tag map: %w", err)
 		}
 	}
 
-	tagList, ok := g.tagsByPrefix[prefix]
-	if !ok {
-		return nil, nil
-	}
+	return slices.Clone(g.tagsByPrefix[prefix]), nil
+}
 
-	return &tagList[0], nil
+func joinPrefix(prefix string, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
 }
 
 func (g *GitRepo) constructTagsByPrefixMap(ctx context.Context) error {
@@ -174,26 +429,9 @@ func (g *GitRepo) constructTagsByPrefixMap(ctx context.Context) error {
 	tagMap := map[string][]Tag{}
 	err = tagIter.ForEach(func(tag *plumbing.Reference) error {
 		log.Trace().Msgf("processing tag %v", tag.Name().Short())
-		prefix := ""
-		tagName := tag.Name().Short()
-
-		// Check if its got a prefix, if so separate the two
-		if strings.Contains(tagName, "/") {
-			log.Trace().Msg("tag contains '/', attempting processing as prefixed tag")
-			parts := strings.Split(tagName, "/")
-			if len(parts) != 2 {
-				log.Debug().Msgf("tag ref %v not in format '<prefix>/<semver>', dropping", tagName)
-				return nil
-			}
-
-			prefix = parts[0]
-			tagName = parts[1]
-		}
-
-		// parse the tag as semver
-		ver, err := semver.NewVersion(tagName)
-		if err != nil {
-			log.Debug().Err(err).Msgf("error parsing tag '%v' as semver, dropping", tagName)
+		prefix, tagName, ver, ok := splitTagName(tag.Name().Short())
+		if !ok {
+			log.Debug().Msgf("tag %v not in format '[<prefix>/]<semver>', dropping", tag.Name().Short())
 			return nil
 		}
 
@@ -245,6 +483,26 @@ func (g *GitRepo) constructTagsByPrefixMap(ctx context.Context) error {
 	return nil
 }
 
+// splitTagName splits a tag name into its prefix, if any, and semver version
+func splitTagName(name string) (string, string, *semver.Version, bool) {
+	prefix := ""
+	tagName := name
+	if strings.Contains(name, "/") {
+		parts := strings.Split(name, "/")
+		if len(parts) != 2 {
+			return "", "", nil, false
+		}
+		prefix = parts[0]
+		tagName = parts[1]
+	}
+
+	ver, err := semver.NewVersion(tagName)
+	if err != nil {
+		return "", "", nil, false
+	}
+	return prefix, tagName, ver, true
+}
+
 type CommitProcessFunc func(ctx context.Context, commit *Commit) (bool, error)
 
 type Commit struct {
@@ -252,20 +510,87 @@ type Commit struct {
 	ShortHash string
 	Message   string
 	Files     []string
+	// ParentHashes are the hashes of the commit's parents, first parent first
+	ParentHashes []string
+	// MergedCommits are the commits a merge brought in from other branches, only populated when following first parents
+	// and classifying merges by their branch. Newest first
+	MergedCommits []*Commit
 }
 
-func (g *GitRepo) MakeTagsAtHead(ctx context.Context, tags ...string) error {
-	head, err := g.repo.Head()
+// targetHash resolves the commit being tagged, HEAD unless a ref was configured
+func (g *GitRepo) targetHash() (plumbing.Hash, error) {
+	if g.ref == "" {
+		head, err := g.repo.Head()
+		if err != nil {
+			// huehuehue
+			return plumbing.ZeroHash, fmt.Errorf("error getting head: %w", err)
+		}
+		return head.Hash(), nil
+	}
+
+	hash, err := g.repo.ResolveRevision(plumbing.Revision(g.ref))
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error resolving ref %v: %w", g.ref, err)
+	}
+	return *hash, nil
+}
+
+// Root returns the top level directory of the worktree, or an empty string for bare repos, which have none
+func (g *GitRepo) Root() (string, error) {
+	w, err := g.repo.Worktree()
+	if errors.Is(err, gogit.ErrIsBareRepository) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error getting worktree: %w", err)
+	}
+	return w.Filesystem.Root(), nil
+}
+
+func (g *GitRepo) targetName() string {
+	if g.ref == "" {
+		return "HEAD"
+	}
+	return g.ref
+}
+
+// MakeTagsAtTarget creates the version tag at the commit being tagged, failing if it already exists, as a published
+// release must never change. The moving tags (such as "latest") replace any existing tags of the same name. Every tag
+// touched is returned, even on error, so they can be rolled back
+func (g *GitRepo) MakeTagsAtTarget(ctx context.Context, version string, moving ...string) ([]CreatedTag, error) {
+	target, err := g.targetHash()
 	if err != nil {
-		// huehuehue
-		return fmt.Errorf("error getting head: %w", err)
+		return nil, err
 	}
 
-	for _, tag := range tags {
-		if err := g.repo.DeleteTag(tag); err != nil && !errors.Is(err, gogit.ErrTagNotFound) {
-			return fmt.Errorf("error deleting old tag: %w", err)
+	// the cached tags no longer reflect the repo
+	defer func() {
+		g.tagsByPrefix = nil
+	}()
+
+	created := []CreatedTag{}
+	for i, tag := range append([]string{version}, moving...) {
+		previous, err := g.repo.Reference(plumbing.NewTagReferenceName(tag), false)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			previous = nil
+		} else if err != nil {
+			return created, fmt.Errorf("error reading existing tag: %w", err)
 		}
-		_, err = g.repo.CreateTag(tag, head.Hash(), &gogit.CreateTagOptions{
+
+		if previous != nil {
+			if i == 0 {
+				return created, fmt.Errorf("error creating tag: %v %w", tag, gogit.ErrTagExists)
+			}
+			if err := g.repo.DeleteTag(tag); err != nil && !errors.Is(err, gogit.ErrTagNotFound) {
+				return created, fmt.Errorf("error deleting old tag: %w", err)
+			}
+		}
+		created = append(created, CreatedTag{
+			Name:     tag,
+			Previous: previous,
+		})
+
+		_, err = g.repo.CreateTag(tag, target, &gogit.CreateTagOptions{
 			Message: "Created By TagBot",
 			Tagger: &object.Signature{
 				Name:  "TagBot",
@@ -274,49 +599,84 @@ func (g *GitRepo) MakeTagsAtHead(ctx context.Context, tags ...string) error {
 			},
 		})
 		if err != nil {
-			return fmt.Errorf("error creating tag: %w", err)
+			return created, fmt.Errorf("error creating tag: %w", err)
 		}
 	}
 
-	return nil
+	return created, nil
 }
 
-func (g *GitRepo) PushTags(ctx context.Context) error {
-	err := g.repo.Push(&gogit.PushOptions{
-		RemoteName: g.remote,
-		RefSpecs:   []gogitconfig.RefSpec{gogitconfig.RefSpec("refs/tags/*:refs/tags/*")},
-		Auth:       g.auth,
-		Force:      true, // needed, as we may be overwriting a "latest" tag
-	})
-	if err != nil {
-		return fmt.Errorf("error pushing: %w", err)
+// RollbackTags undoes MakeTagsAtTarget, deleting the created tags and restoring any they replaced
+func (g *GitRepo) RollbackTags(ctx context.Context, created []CreatedTag) error {
+	defer func() {
+		g.tagsByPrefix = nil
+	}()
+
+	errs := []error{}
+	for _, tag := range slices.Backward(created) {
+		if err := g.repo.DeleteTag(tag.Name); err != nil && !errors.Is(err, gogit.ErrTagNotFound) {
+			errs = append(errs, fmt.Errorf("error deleting %v: %w", tag.Name, err))
+			continue
+		}
+		if tag.Previous != nil {
+			if err := g.repo.Storer.SetReference(tag.Previous); err != nil {
+				errs = append(errs, fmt.Errorf("error restoring %v: %w", tag.Name, err))
+			}
+		}
 	}
-	return nil
+
+	return errors.Join(errs...)
 }
 
-func (g *GitRepo) ProcessLogWhere(ctx context.Context, stopFunc func(commit *object.Commit) bool, processFunc CommitProcessFunc) error {
-	iter, err := g.repo.Log(&gogit.LogOptions{
-		Order: gogit.LogOrderCommitterTime,
-	})
+type LogOptions struct {
+	Strategy            config.WalkKind
+	MergeClassification config.MergeKind
+	// PathGlobs returns the change set globs of each component still interested in commits, and is called before each
+	// commit is diffed. Only paths those globs could match are diffed, and diffing a commit stops as soon as each
+	// component has a matching path. When nil, every changed path is included
+	PathGlobs func() [][]string
+}
+
+func (g *GitRepo) ProcessLogWhere(ctx context.Context, opts LogOptions, stopFunc func(commit *object.Commit) bool, processFunc CommitProcessFunc) error {
+	walk, err := g.logWalker(opts)
 	if err != nil {
 		return fmt.Errorf("error constructing iterator: %w", err)
 	}
 
+	firstParent := opts.Strategy == config.WalkKindFirstParent
+	classifyByBranch := firstParent && opts.MergeClassification == config.MergeKindBranch
+
 	stopIterationErr := errors.New("__internal_stop_iteration_error")
-	err = iter.ForEach(func(commit *object.Commit) error {
+	err = walk(func(commit *object.Commit) error {
 		if stopFunc(commit) {
 			return stopIterationErr
 		}
-		files, err := g.getFilesForCommit(commit)
+
+		// When only following first parents and classifying merges by their message, the merge is the only commit seen
+		// for the branch, so it should carry every change the branch made. Otherwise the commits of the branch are seen
+		// individually, and the merge itself only carries what it changed relative to all of its parents
+		var globs [][]string
+		if opts.PathGlobs != nil {
+			globs = opts.PathGlobs()
+		}
+
+		c, err := g.newCommit(ctx, commit, firstParent && !classifyByBranch, globs)
 		if err != nil {
-			return fmt.Errorf("error getting files: %w", err)
+			return err
 		}
 
-		c := &Commit{
-			Hash:      commit.Hash.String(),
-			ShortHash: commit.Hash.String()[:10],
-			Message:   commit.Message,
-			Files:     files,
+		if classifyByBranch && commit.NumParents() > 1 {
+			merged, err := mergedCommits(g.commitNodes(ctx), commit)
+			if err != nil {
+				return fmt.Errorf("error getting merged commits: %w", err)
+			}
+			for _, m := range merged {
+				mc, err := g.newCommit(ctx, m, false, globs)
+				if err != nil {
+					return err
+				}
+				c.MergedCommits = append(c.MergedCommits, mc)
+			}
 		}
 
 		shouldContinue, err := processFunc(ctx, c)
@@ -338,69 +698,248 @@ func (g *GitRepo) ProcessLogWhere(ctx context.Context, stopFunc func(commit *obj
 	return nil
 }
 
-func (g *GitRepo) getFilesForCommit(commit *object.Commit) ([]string, error) {
-	// "WTF is this?!"
-	// So apparently https://github.com/go-git/go-git/issues/307 is like...how this is supposed to work? which is
-	// insane to me.
-	// https://github.com/metio/terraform-provider-git/commit/fbedb640bc03c4d3ebda8ab75653d18dc1d32277 introduced
-	// the idea of computing patches, but the default version tried to also get patch _content_, which for commits
-	// that had a large number of files, or large files (cough PF gamp-config), it would balloon memory like crazy.
-	// We dont actually need the file content, only the changed file paths and the message. So this is basically the guts
-	// of `patch.FilePatches().Files()` but with the content gathering ripped out.
-	files := []string{}
+// logWalker constructs a function that calls the given function for each commit reachable from the target, in the order
+// dictated by the walk strategy
+func (g *GitRepo) logWalker(opts LogOptions) (func(fn func(*object.Commit) error) error, error) {
+	target, err := g.targetHash()
+	if err != nil {
+		return nil, err
+	}
 
-	if commit.NumParents() == 0 {
-		fileIter, err := commit.Files()
+	if opts.Strategy == "" || opts.Strategy == config.WalkKindAll {
+		iter, err := g.repo.Log(&gogit.LogOptions{
+			From:  target,
+			Order: gogit.LogOrderCommitterTime,
+		})
 		if err != nil {
-			return nil, fmt.Errorf("error creating file iter: %w", err)
+			return nil, err
 		}
-		err = fileIter.ForEach(func(f *object.File) error {
-			files = append(files, f.Name)
-			return nil
-		})
+		return iter.ForEach, nil
+	}
+
+	tip, err := g.repo.CommitObject(target)
+	if err != nil {
+		return nil, fmt.Errorf("error getting %v commit: %w", g.targetName(), err)
+	}
+
+	switch opts.Strategy {
+	case config.WalkKindFirstParent:
+		return func(fn func(*object.Commit) error) error {
+			current := tip
+			for {
+				if err := fn(current); err != nil {
+					return err
+				}
+				if current.NumParents() == 0 {
+					return nil
+				}
+				parent, err := current.Parent(0)
+				if err != nil {
+					return fmt.Errorf("error getting parent: %w", err)
+				}
+				current = parent
+			}
+		}, nil
+	case config.WalkKindTopological:
+		// the whole history is read before the first commit is handed out, so stopping early only saves diffing
+		boundary, err := g.shallowBoundary()
 		if err != nil {
-			return nil, fmt.Errorf("error iterating files: %w", err)
+			return nil, fmt.Errorf("error checking for shallow clone: %w", err)
 		}
-		return files, nil
+		commits, err := topologicalOrder(tip, boundary)
+		if err != nil {
+			return nil, err
+		}
+		return func(fn func(*object.Commit) error) error {
+			for _, commit := range commits {
+				if err := fn(commit); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unhandled walk strategy %v", opts.Strategy)
 	}
+}
 
-	parent, err := commit.Parent(0)
+func (g *GitRepo) newCommit(ctx context.Context, commit *object.Commit, mergeAgainstFirstParent bool, globs [][]string) (*Commit, error) {
+	var files []string
+	var err error
+	if commit.NumParents() > 1 && !mergeAgainstFirstParent {
+		files, err = g.getFilesForMerge(commit, globs)
+	} else {
+		files, err = g.cachedFilesForCommit(ctx, commit, newPathFilter(globs, true))
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error getting parent: %w", err)
+		return nil, fmt.Errorf("error getting files: %w", err)
+	}
+
+	parents := []string{}
+	for _, parent := range commit.ParentHashes {
+		parents = append(parents, parent.String())
 	}
 
-	parentTree, err := parent.Tree()
+	return &Commit{
+		Hash:         commit.Hash.String(),
+		ShortHash:    commit.Hash.String()[:10],
+		Message:      commit.Message,
+		Files:        files,
+		ParentHashes: parents,
+	}, nil
+}
+
+func (g *GitRepo) IsAncestor(ctx context.Context, ancestor string, descendant string) (bool, error) {
+	if g.ancestryWalkers == nil {
+		g.ancestryWalkers = map[string]*ancestryWalker{}
+		g.nodeParents = parentCache{}
+	}
+
+	nodes := g.commitNodes(ctx)
+
+	walker, ok := g.ancestryWalkers[descendant]
+	if !ok {
+		tip, err := nodes.Get(plumbing.NewHash(descendant))
+		if err != nil {
+			return false, fmt.Errorf("error getting commit %v: %w", descendant, err)
+		}
+		walker = newAncestryWalker(tip, g.nodeParents)
+		g.ancestryWalkers[descendant] = walker
+	}
+
+	node, err := nodes.Get(plumbing.NewHash(ancestor))
 	if err != nil {
-		return nil, fmt.Errorf("error getting parent tree: %w", err)
+		return false, fmt.Errorf("error getting commit %v: %w", ancestor, err)
+	}
+
+	return walker.Contains(node)
+}
+
+// commitNodes returns the index used to walk history, reading from the commit-graph file when configured to and one
+// is present, and commit objects otherwise
+func (g *GitRepo) commitNodes(ctx context.Context) commitgraph.CommitNodeIndex {
+	if g.nodeIndex != nil {
+		return g.nodeIndex
+	}
+
+	g.nodeIndex = commitgraph.NewObjectCommitNodeIndex(g.repo.Storer)
+	if !g.useCommitGraph {
+		return g.nodeIndex
+	}
+
+	log := zerolog.Ctx(ctx)
+	fsStorage, ok := g.repo.Storer.(*filesystem.Storage)
+	if !ok {
+		log.Debug().Msg("repository storage has no commit-graph, reading commit objects")
+		return g.nodeIndex
 	}
-	commitTree, err := commit.Tree()
+	index, err := commitgraphfmt.OpenChainOrFileIndex(fsStorage.Filesystem())
 	if err != nil {
-		return nil, fmt.Errorf("error getting commit tree: %w", err)
+		log.Debug().Err(err).Msg("unable to open commit-graph, reading commit objects")
+		return g.nodeIndex
 	}
 
-	parentObj := object.NewTreeRootNode(parentTree)
-	commitObj := object.NewTreeRootNode(commitTree)
+	log.Debug().Msg("using commit-graph")
+	g.nodeIndex = commitgraph.NewGraphCommitNodeIndex(index, g.repo.Storer)
+	return g.nodeIndex
+}
 
-	hashEqual := func(a, b noder.Hasher) bool {
-		return bytes.Equal(a.Hash(), b.Hash())
+// cachedFilesForCommit is getFilesForCommit, but consults the file cache first when there is one. The cache holds every
+// changed path regardless of the filter, so entries can be reused by runs with different components
+func (g *GitRepo) cachedFilesForCommit(ctx context.Context, commit *object.Commit, filter *pathFilter) ([]string, error) {
+	if g.fileCache == nil {
+		return g.getFilesForCommit(commit, filter)
+	}
+
+	files, ok := g.fileCache.Get(commit.Hash)
+	if !ok {
+		all, err := g.getFilesForCommit(commit, nil)
+		if err != nil {
+			return nil, err
+		}
+		// failing to cache only costs time on the next run, so isn't worth failing this one over
+		if err := g.fileCache.Put(commit.Hash, all); err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Msgf("unable to cache files for %v", commit.Hash)
+		}
+		files = all
+	}
+
+	return filter.apply(files)
+}
+
+func (g *GitRepo) getFilesForCommit(commit *object.Commit, filter *pathFilter) ([]string, error) {
+	// "WTF is this?!"
+	// So apparently https://github.com/go-git/go-git/issues/307 is like...how this is supposed to work? which is
+	// insane to me.
+	// https://github.com/metio/terraform-provider-git/commit/fbedb640bc03c4d3ebda8ab75653d18dc1d32277 introduced
+	// the idea of computing patches, but the default version tried to also get patch _content_, which for commits
+	// that had a large number of files, or large files (cough PF gamp-config), it would balloon memory like crazy.
+	// We dont actually need the file content, only the changed file paths and the message. So diffCommits only ever
+	// compares trees, and skips over the parts of them nobody is interested in
+	if commit.NumParents() == 0 {
+		return g.diffCommits(nil, commit, filter)
 	}
 
-	changes, err := merkletrie.DiffTree(parentObj, commitObj, hashEqual)
+	parent, err := commit.Parent(0)
 	if err != nil {
-		return nil, fmt.Errorf("error computing diff: %w", err)
+		return nil, fmt.Errorf("error getting parent: %w", err)
 	}
 
-	for _, c := range changes {
-		if c.From != nil {
-			files = append(files, c.From.String())
-		} else if c.To != nil {
-			files = append(files, c.To.String())
+	return g.diffCommits(parent, commit, filter)
+}
+
+// getFilesForMerge returns the files a merge changed relative to every one of its parents, i.e the files that needed
+// conflict resolution or were otherwise changed in the merge itself
+func (g *GitRepo) getFilesForMerge(commit *object.Commit, globs [][]string) ([]string, error) {
+	var files []string
+	err := commit.Parents().ForEach(func(parent *object.Commit) error {
+		// every changed path is needed to intersect properly, so the diff can't stop early
+		changed, err := g.diffCommits(parent, commit, newPathFilter(globs, false))
+		if err != nil {
+			return err
+		}
+
+		if files == nil {
+			files = changed
+			return nil
 		}
+		files = slices.DeleteFunc(files, func(file string) bool {
+			return !slices.Contains(changed, file)
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	if files == nil {
+		files = []string{}
+	}
 	return files, nil
 }
 
+// CommitMessage returns the message of the commit with the (possibly abbreviated) hash, and false if there's no such
+// commit, such as when it's beyond the history of a shallow clone
+func (g *GitRepo) CommitMessage(ctx context.Context, hash string) (string, bool, error) {
+	resolved, err := g.repo.ResolveRevision(plumbing.Revision(hash))
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("error resolving %v: %w", hash, err)
+	}
+
+	commit, err := g.repo.CommitObject(*resolved)
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("error getting commit %v: %w", hash, err)
+	}
+
+	return commit.Message, true, nil
+}
+
 func (g *GitRepo) IsTagbotDisabled() (bool, error) {
 	conf, err := g.repo.Config()
 	if err != nil {
@@ -413,3 +952,18 @@ func (g *GitRepo) IsTagbotDisabled() (bool, error) {
 
 	return conf.Raw.Section("tagbot").Option("disable") == "true", nil
 }
+
+func (g *GitRepo) GetCleanupConfig() (CleanupConfig, error) {
+	// cleanup settings are commonly set globally, so include those in addition to the repos own config
+	conf, err := g.repo.ConfigScoped(gogitconfig.GlobalScope)
+	if err != nil {
+		return CleanupConfig{}, fmt.Errorf("error fetching git config: %w", err)
+	}
+
+	return CleanupConfig{
+		CommentChar: conf.Raw.Section("core").Option("commentChar"),
+		Mode:        conf.Raw.Section("commit").Option("cleanup"),
+		// git tells hooks no editor was used by setting the editor to a no-op
+		NoEditor: os.Getenv("GIT_EDITOR") == ":",
+	}, nil
+}
@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/nicjohnson145/tagbot/internal/config"
+	"github.com/rs/zerolog"
+)
+
+// EnsureAllowedBranch makes sure the commit being tagged is on a branch matching one of the allowed globs, defaulting to
+// the remote's default branch. Detached checkouts are identified by the branch CI says they're for, or failing that,
+// are allowed if contained in an allowed branch of the remote
+func (g *GitRepo) EnsureAllowedBranch(ctx context.Context, allowed []string) error {
+	log := zerolog.Ctx(ctx)
+
+	if len(allowed) == 0 {
+		branch, err := g.defaultBranch()
+		if err != nil {
+			return fmt.Errorf("error determining default branch: %w", err)
+		}
+		if branch == "" {
+			log.Warn().Msgf("unable to determine the default branch of %v, so not restricting what branches are released from. Set --%v to do so", g.remote, config.AllowedBranches)
+			return nil
+		}
+		allowed = []string{branch}
+	}
+
+	branch, err := g.targetBranch()
+	if err != nil {
+		return err
+	}
+
+	if branch != "" {
+		ok, err := branchAllowed(branch, allowed)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: %v is on %v, which doesn't match any of %v", ErrBranchNotAllowed, g.targetName(), branch, allowed)
+		}
+		return nil
+	}
+
+	containing, err := g.allowedRemoteBranchContaining(ctx, allowed)
+	if err != nil {
+		return err
+	}
+	if containing == "" {
+		return fmt.Errorf("%w: %v isn't on a branch, and no branch of %v matching %v contains it", ErrBranchNotAllowed, g.targetName(), g.remote, allowed)
+	}
+	log.Debug().Msgf("%v is detached, but contained in %v", g.targetName(), containing)
+
+	return nil
+}
+
+func branchAllowed(branch string, allowed []string) (bool, error) {
+	for _, glob := range allowed {
+		match, err := doublestar.Match(glob, branch)
+		if err != nil {
+			return false, fmt.Errorf("error checking allowed branch %v: %w", glob, err)
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// defaultBranch returns the default branch of the remote, or an empty string if it's unknown
+func (g *GitRepo) defaultBranch() (string, error) {
+	ref, err := g.repo.Reference(plumbing.NewRemoteHEADReferenceName(g.remote), false)
+	if err != nil && !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return "", fmt.Errorf("error reading HEAD of %v: %w", g.remote, err)
+	}
+	if err == nil && ref.Type() == plumbing.SymbolicReference {
+		return strings.TrimPrefix(ref.Target().String(), plumbing.NewRemoteReferenceName(g.remote, "").String()), nil
+	}
+
+	// actions/checkout doesn't record the remote's HEAD, but the event that triggered the workflow does
+	if path := os.Getenv("GITHUB_EVENT_PATH"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("error reading github event: %w", err)
+		}
+
+		var event struct {
+			Repository struct {
+				DefaultBranch string `json:"default_branch"`
+			} `json:"repository"`
+		}
+		if err := json.Unmarshal(content, &event); err != nil {
+			return "", fmt.Errorf("error parsing github event: %w", err)
+		}
+		return event.Repository.DefaultBranch, nil
+	}
+
+	return "", nil
+}
+
+// targetBranch returns the name of the branch being tagged, or an empty string if that's not a branch
+func (g *GitRepo) targetBranch() (string, error) {
+	if g.ref != "" {
+		for _, name := range []plumbing.ReferenceName{plumbing.ReferenceName(g.ref), plumbing.NewBranchReferenceName(g.ref)} {
+			if !name.IsBranch() {
+				continue
+			}
+			if _, err := g.repo.Reference(name, false); err == nil {
+				return name.Short(), nil
+			}
+		}
+
+		// a branch of the remote is as good as the local one
+		remotePrefix := g.remote + "/"
+		if strings.HasPrefix(g.ref, remotePrefix) {
+			name := plumbing.NewRemoteReferenceName(g.remote, strings.TrimPrefix(g.ref, remotePrefix))
+			if _, err := g.repo.Reference(name, false); err == nil {
+				return strings.TrimPrefix(g.ref, remotePrefix), nil
+			}
+		}
+
+		return "", nil
+	}
+
+	head, err := g.repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return "", fmt.Errorf("error reading HEAD: %w", err)
+	}
+	if head.Type() == plumbing.SymbolicReference && head.Target().IsBranch() {
+		return head.Target().Short(), nil
+	}
+
+	// CI checkouts are usually detached, but say which branch they're for
+	if os.Getenv("GITHUB_REF_TYPE") == "branch" && os.Getenv("GITHUB_REF_NAME") != "" {
+		return os.Getenv("GITHUB_REF_NAME"), nil
+	}
+	if branch := os.Getenv("CI_COMMIT_BRANCH"); branch != "" {
+		return branch, nil
+	}
+
+	return "", nil
+}
+
+// allowedRemoteBranchContaining returns the first allowed branch of the remote that contains the commit being tagged,
+// or an empty string if none do
+func (g *GitRepo) allowedRemoteBranchContaining(ctx context.Context, allowed []string) (string, error) {
+	target, err := g.targetHash()
+	if err != nil {
+		return "", err
+	}
+
+	refs, err := g.repo.References()
+	if err != nil {
+		return "", fmt.Errorf("error listing references: %w", err)
+	}
+
+	remotePrefix := plumbing.NewRemoteReferenceName(g.remote, "").String()
+	containing := ""
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference || !strings.HasPrefix(ref.Name().String(), remotePrefix) {
+			return nil
+		}
+
+		ok, err := branchAllowed(strings.TrimPrefix(ref.Name().String(), remotePrefix), allowed)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+
+		contained, err := g.IsAncestor(ctx, target.String(), ref.Hash().String())
+		if err != nil {
+			return fmt.Errorf("error checking if %v contains %v: %w", ref.Name().Short(), g.targetName(), err)
+		}
+		if contained {
+			containing = ref.Name().Short()
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return containing, nil
+}
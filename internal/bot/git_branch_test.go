@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureAllowedBranch(t *testing.T) {
+	commits := []testCommit{
+		{Message: "feat: one", Files: []string{"foo"}},
+		{Message: "feat: two", Files: []string{"foo"}},
+	}
+
+	setRef := func(t *testing.T, repo *unitTestRepo, ref *plumbing.Reference) {
+		t.Helper()
+		require.NoError(t, repo.repo.Storer.SetReference(ref))
+	}
+
+	headHash := func(t *testing.T, repo *unitTestRepo) plumbing.Hash {
+		t.Helper()
+		head, err := repo.repo.Head()
+		require.NoError(t, err)
+		return head.Hash()
+	}
+
+	t.Run("allowed glob", func(t *testing.T) {
+		repo, g := newMemoryGitRepo(t, commits...)
+		g.remote = "origin"
+		repo.Checkout(t, "release/1.x", true)
+
+		require.NoError(t, g.EnsureAllowedBranch(newCtxWithLog(t), []string{"main", "release/*"}))
+		require.ErrorIs(t, g.EnsureAllowedBranch(newCtxWithLog(t), []string{"main"}), ErrBranchNotAllowed)
+	})
+
+	t.Run("default branch of remote", func(t *testing.T) {
+		repo, g := newMemoryGitRepo(t, commits...)
+		g.remote = "origin"
+		setRef(t, repo, plumbing.NewSymbolicReference(plumbing.NewRemoteHEADReferenceName("origin"), plumbing.NewRemoteReferenceName("origin", "main")))
+
+		require.ErrorIs(t, g.EnsureAllowedBranch(newCtxWithLog(t), nil), ErrBranchNotAllowed)
+
+		repo.Checkout(t, "main", true)
+		require.NoError(t, g.EnsureAllowedBranch(newCtxWithLog(t), nil))
+	})
+
+	t.Run("default branch from github event", func(t *testing.T) {
+		_, g := newMemoryGitRepo(t, commits...)
+		g.remote = "origin"
+		eventPath := filepath.Join(t.TempDir(), "event.json")
+		require.NoError(t, os.WriteFile(eventPath, []byte(`{"repository":{"default_branch":"trunk"}}`), 0644))
+		t.Setenv("GITHUB_EVENT_PATH", eventPath)
+
+		require.ErrorIs(t, g.EnsureAllowedBranch(newCtxWithLog(t), nil), ErrBranchNotAllowed)
+	})
+
+	t.Run("unknown default branch", func(t *testing.T) {
+		_, g := newMemoryGitRepo(t, commits...)
+		g.remote = "origin"
+		require.NoError(t, g.EnsureAllowedBranch(newCtxWithLog(t), nil))
+	})
+
+	t.Run("configured ref", func(t *testing.T) {
+		repo, g := newMemoryGitRepo(t, commits...)
+		g.remote = "origin"
+		repo.Checkout(t, "feature", true)
+		repo.Checkout(t, "master", false)
+
+		g.ref = "feature"
+		require.ErrorIs(t, g.EnsureAllowedBranch(newCtxWithLog(t), []string{"master"}), ErrBranchNotAllowed)
+		g.ref = "refs/heads/master"
+		require.NoError(t, g.EnsureAllowedBranch(newCtxWithLog(t), []string{"master"}))
+	})
+
+	t.Run("detached with ci branch", func(t *testing.T) {
+		repo, g := newMemoryGitRepo(t, commits...)
+		g.remote = "origin"
+		setRef(t, repo, plumbing.NewHashReference(plumbing.HEAD, headHash(t, repo)))
+
+		t.Setenv("GITHUB_REF_TYPE", "branch")
+		t.Setenv("GITHUB_REF_NAME", "main")
+		require.NoError(t, g.EnsureAllowedBranch(newCtxWithLog(t), []string{"main"}))
+
+		t.Setenv("GITHUB_REF_NAME", "feature")
+		require.ErrorIs(t, g.EnsureAllowedBranch(newCtxWithLog(t), []string{"main"}), ErrBranchNotAllowed)
+
+		t.Setenv("GITHUB_REF_TYPE", "")
+		t.Setenv("CI_COMMIT_BRANCH", "main")
+		require.NoError(t, g.EnsureAllowedBranch(newCtxWithLog(t), []string{"main"}))
+	})
+
+	t.Run("detached contained in remote branch", func(t *testing.T) {
+		repo, g := newMemoryGitRepo(t, commits...)
+		g.remote = "origin"
+		hashes := repo.MakeCommits(t, testCommit{Message: "feat: three", Files: []string{"foo"}})
+		setRef(t, repo, plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", "main"), hashes[0]))
+		setRef(t, repo, plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", "feature"), hashes[0]))
+
+		// an ancestor of the remote branch is contained in it
+		commit, err := repo.repo.CommitObject(hashes[0])
+		require.NoError(t, err)
+		setRef(t, repo, plumbing.NewHashReference(plumbing.HEAD, commit.ParentHashes[0]))
+
+		require.NoError(t, g.EnsureAllowedBranch(newCtxWithLog(t), []string{"main"}))
+		require.ErrorIs(t, g.EnsureAllowedBranch(newCtxWithLog(t), []string{"release/*"}), ErrBranchNotAllowed)
+	})
+}
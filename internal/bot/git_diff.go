@@ -0,0 +1,264 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var errDiffComplete = errors.New("__internal_diff_complete_error")
+
+// pathFilter narrows a diff down to the paths that matter to a set of components. Directories that none of the
+// components could match are never read, and when stopping early, diffing stops as soon as every component has a
+// matching path
+type pathFilter struct {
+	globs     [][]string
+	matched   []bool
+	remaining int
+	stopEarly bool
+}
+
+// newPathFilter creates a filter over the change set globs of each component. A nil filter is returned when there are
+// no components to filter for, as every path is needed
+func newPathFilter(globs [][]string, stopEarly bool) *pathFilter {
+	if globs == nil {
+		return nil
+	}
+
+	return &pathFilter{
+		globs:     globs,
+		matched:   make([]bool, len(globs)),
+		remaining: len(globs),
+		stopEarly: stopEarly,
+	}
+}
+
+// wantDir reports if any file under the directory could matter
+func (p *pathFilter) wantDir(dir string) bool {
+	if p == nil {
+		return true
+	}
+
+	for i, globs := range p.globs {
+		if p.stopEarly && p.matched[i] {
+			continue
+		}
+		for _, glob := range globs {
+			if globMayMatchUnder(glob, dir) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// add records a changed file, reporting if it matters to any component
+func (p *pathFilter) add(file string) (bool, error) {
+	if p == nil {
+		return true, nil
+	}
+
+	keep := false
+	for i, globs := range p.globs {
+		for _, glob := range globs {
+			match, err := doublestar.Match(glob, file)
+			if err != nil {
+				return false, fmt.Errorf("error checking glob match: %w", err)
+			}
+			if match {
+				keep = true
+				if !p.matched[i] {
+					p.matched[i] = true
+					p.remaining--
+				}
+				break
+			}
+		}
+	}
+	return keep, nil
+}
+
+// apply narrows an already computed list of files down to those that matter, stopping early the same way a diff would
+func (p *pathFilter) apply(files []string) ([]string, error) {
+	if p == nil {
+		return files, nil
+	}
+
+	kept := []string{}
+	for _, file := range files {
+		keep, err := p.add(file)
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			kept = append(kept, file)
+		}
+		if p.done() {
+			break
+		}
+	}
+	return kept, nil
+}
+
+// done reports if nothing more needs to be diffed
+func (p *pathFilter) done() bool {
+	return p != nil && p.stopEarly && p.remaining == 0
+}
+
+// globMayMatchUnder reports if the glob could match any path within the directory, by matching the directory segment
+// by segment. It errs on the side of caution, so braces (which may contain separators) always could
+func globMayMatchUnder(glob string, dir string) bool {
+	if strings.Contains(glob, "{") {
+		return true
+	}
+
+	globSegments := strings.Split(glob, "/")
+	dirSegments := strings.Split(dir, "/")
+	for i, segment := range dirSegments {
+		if i >= len(globSegments) {
+			return false
+		}
+		if globSegments[i] == "**" {
+			return true
+		}
+		match, err := doublestar.Match(globSegments[i], segment)
+		if err != nil || !match {
+			return err != nil
+		}
+	}
+	// the glob needs something deeper than the directory itself to match
+	return len(globSegments) > len(dirSegments)
+}
+
+// diffCommits lists every path changed between the two commits that passes the filter. Both sides of a change are
+// included, so a file moved between components counts towards both of them. A nil from commit diffs against nothing
+func (g *GitRepo) diffCommits(from *object.Commit, to *object.Commit, filter *pathFilter) ([]string, error) {
+	var fromTree *object.Tree
+	if from != nil {
+		t, err := from.Tree()
+		if err != nil {
+			return nil, fmt.Errorf("error getting parent tree: %w", err)
+		}
+		fromTree = t
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("error getting commit tree: %w", err)
+	}
+
+	files := []string{}
+	seen := map[string]struct{}{}
+	addFile := func(file string) error {
+		if file == "" {
+			return nil
+		}
+		if _, ok := seen[file]; ok {
+			return nil
+		}
+		seen[file] = struct{}{}
+		keep, err := filter.add(file)
+		if err != nil {
+			return err
+		}
+		if keep {
+			files = append(files, file)
+		}
+		if filter.done() {
+			return errDiffComplete
+		}
+		return nil
+	}
+
+	return finishDiff(files, diffTrees("", fromTree, toTree, filter, addFile))
+}
+
+func finishDiff(files []string, err error) ([]string, error) {
+	if err != nil && !errors.Is(err, errDiffComplete) {
+		return nil, err
+	}
+	return files, nil
+}
+
+// diffTrees walks two trees side by side, calling addFile for each path that differs. Subtrees that are identical, or
+// that the filter has no interest in, are skipped without being read. Only tree objects are ever read, never file
+// content, which keeps memory in check for commits touching many (or large) files
+func diffTrees(dir string, from *object.Tree, to *object.Tree, filter *pathFilter, addFile func(string) error) error {
+	fromEntries := treeEntries(from)
+	toEntries := treeEntries(to)
+
+	names := []string{}
+	for name := range fromEntries {
+		names = append(names, name)
+	}
+	for name := range toEntries {
+		if _, ok := fromEntries[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		fromEntry, inFrom := fromEntries[name]
+		toEntry, inTo := toEntries[name]
+		if inFrom && inTo && fromEntry.Hash == toEntry.Hash && fromEntry.Mode == toEntry.Mode {
+			continue
+		}
+
+		entryPath := path.Join(dir, name)
+		fromIsDir := inFrom && fromEntry.Mode == filemode.Dir
+		toIsDir := inTo && toEntry.Mode == filemode.Dir
+
+		// a file replaced by a directory (or vice versa) is a change to the file, in addition to whatever the directory
+		// contains
+		if (inFrom && !fromIsDir) || (inTo && !toIsDir) {
+			if err := addFile(entryPath); err != nil {
+				return err
+			}
+		}
+
+		if !fromIsDir && !toIsDir {
+			continue
+		}
+		if !filter.wantDir(entryPath) {
+			continue
+		}
+
+		var fromSubtree, toSubtree *object.Tree
+		if fromIsDir {
+			t, err := from.Tree(name)
+			if err != nil {
+				return fmt.Errorf("error getting tree %v: %w", entryPath, err)
+			}
+			fromSubtree = t
+		}
+		if toIsDir {
+			t, err := to.Tree(name)
+			if err != nil {
+				return fmt.Errorf("error getting tree %v: %w", entryPath, err)
+			}
+			toSubtree = t
+		}
+		if err := diffTrees(entryPath, fromSubtree, toSubtree, filter, addFile); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func treeEntries(tree *object.Tree) map[string]object.TreeEntry {
+	entries := map[string]object.TreeEntry{}
+	if tree == nil {
+		return entries
+	}
+	for _, entry := range tree.Entries {
+		entries[entry.Name] = entry
+	}
+	return entries
+}
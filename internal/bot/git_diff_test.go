@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobMayMatchUnder(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		name     string
+		glob     string
+		dir      string
+		expected bool
+	}{
+		{name: "double star", glob: "**/*", dir: "anything/at/all", expected: true},
+		{name: "prefix of glob", glob: "services/api/**", dir: "services", expected: true},
+		{name: "within glob", glob: "services/api/**", dir: "services/api/internal", expected: true},
+		{name: "sibling", glob: "services/api/**", dir: "services/web", expected: false},
+		{name: "unrelated", glob: "services/api/**", dir: "libs", expected: false},
+		{name: "single star segment", glob: "services/*/main.go", dir: "services/web", expected: true},
+		{name: "too deep", glob: "foo/*", dir: "foo/bar", expected: false},
+		{name: "braces are always walked", glob: "{libs,services/api}/**", dir: "docs", expected: true},
+	}
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.expected, globMayMatchUnder(tc.glob, tc.dir))
+		})
+	}
+}
+
+func TestDiffCommitsFiltered(t *testing.T) {
+	repo := newMemoryRepo(
+		t,
+		testCommit{
+			Message: "feat: initial",
+			Files:   []string{"libs/a.go", "services/api/main.go", "services/web/main.go"},
+		},
+		testCommit{
+			Message: "feat: everything",
+			Files:   []string{"libs/a.go", "libs/b.go", "services/api/main.go", "services/web/main.go"},
+		},
+	)
+	head, err := repo.repo.Head()
+	require.NoError(t, err)
+	commit, err := repo.repo.CommitObject(head.Hash())
+	require.NoError(t, err)
+
+	g := GitRepo{}
+
+	t.Run("unfiltered", func(t *testing.T) {
+		got, err := g.getFilesForCommit(commit, nil)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"libs/a.go", "libs/b.go", "services/api/main.go", "services/web/main.go"}, got)
+	})
+
+	t.Run("pruned", func(t *testing.T) {
+		got, err := g.getFilesForCommit(commit, newPathFilter([][]string{{"services/api/**"}, {"services/web/**"}}, false))
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"services/api/main.go", "services/web/main.go"}, got)
+	})
+
+	t.Run("stops once every component matched", func(t *testing.T) {
+		got, err := g.getFilesForCommit(commit, newPathFilter([][]string{{"libs/**"}}, true))
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+	})
+
+	t.Run("root commit", func(t *testing.T) {
+		root, err := commit.Parent(0)
+		require.NoError(t, err)
+
+		got, err := g.getFilesForCommit(root, newPathFilter([][]string{{"libs/**"}}, false))
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"libs/a.go"}, got)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/Masterminds/semver"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
@@ -13,10 +14,26 @@ type Tag struct {
 	TagName string
 }
 
+// CreatedTag records a tag made locally, along with whatever it replaced (such as a "latest" tag being moved), so it
+// can be undone
+type CreatedTag struct {
+	Name     string
+	Previous *plumbing.Reference
+}
+
 type IRepo interface {
+	EnsureHistory(ctx context.Context, prefixes []string) error
+	EnsureAllowedBranch(ctx context.Context, allowed []string) error
+	EnsureReadyToTag(ctx context.Context) error
 	GetLatestTag(ctx context.Context, prefix string) (*Tag, error)
-	MakeTagsAtHead(ctx context.Context, tags ...string) error
-	PushTags(ctx context.Context) error
+	GetTags(ctx context.Context, prefix string) ([]Tag, error)
+	GetTagsAtTarget(ctx context.Context, prefix string) ([]Tag, error)
+	MakeTagsAtTarget(ctx context.Context, version string, moving ...string) ([]CreatedTag, error)
+	RollbackTags(ctx context.Context, created []CreatedTag) error
+	PushTags(ctx context.Context, created []CreatedTag) ([]CreatedTag, error)
 	IsTagbotDisabled() (bool, error)
-	ProcessLogWhere(ctx context.Context, stopFunc func(commit *object.Commit) bool, processFunc CommitProcessFunc) error
+	GetCleanupConfig() (CleanupConfig, error)
+	ProcessLogWhere(ctx context.Context, opts LogOptions, stopFunc func(commit *object.Commit) bool, processFunc CommitProcessFunc) error
+	IsAncestor(ctx context.Context, ancestor string, descendant string) (bool, error)
+	CommitMessage(ctx context.Context, hash string) (string, bool, error)
 }
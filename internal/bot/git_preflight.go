@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/nicjohnson145/tagbot/internal/config"
+	"github.com/rs/zerolog"
+)
+
+// maxReportedFiles caps how many offending files are named in errors, the rest are only counted
+const maxReportedFiles = 5
+
+// EnsureReadyToTag runs the configured pre-flight checks, making sure what's being tagged is what everyone else will see
+func (g *GitRepo) EnsureReadyToTag(ctx context.Context) error {
+	if g.requireCleanWorktree {
+		if err := g.ensureCleanWorktree(ctx); err != nil {
+			return err
+		}
+	}
+
+	if g.requirePushed {
+		if err := g.ensurePushed(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensureCleanWorktree makes sure no tracked file has uncommitted changes. Untracked files are ignored, as they're
+// frequently build output, and can't have any bearing on what's committed
+func (g *GitRepo) ensureCleanWorktree(ctx context.Context) error {
+	w, err := g.repo.Worktree()
+	if errors.Is(err, gogit.ErrIsBareRepository) {
+		zerolog.Ctx(ctx).Debug().Msg("bare repository has no worktree to check")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting worktree: %w", err)
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return fmt.Errorf("error getting worktree status: %w", err)
+	}
+
+	changed := []string{}
+	for path, s := range status {
+		if s.Staging == gogit.Untracked && s.Worktree == gogit.Untracked {
+			continue
+		}
+		if s.Staging != gogit.Unmodified || s.Worktree != gogit.Unmodified {
+			changed = append(changed, path)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	sort.Strings(changed)
+	listed := changed
+	if len(listed) > maxReportedFiles {
+		listed = listed[:maxReportedFiles]
+	}
+	return fmt.Errorf(
+		"%w: %v file(s) changed, including %v. Commit or stash them, or set --%v=false",
+		ErrDirtyWorktree,
+		len(changed),
+		listed,
+		config.RequireCleanWorktree,
+	)
+}
+
+// ensurePushed fetches the remote, then makes sure the branch being tagged on it contains the commit being tagged, so
+// tags never point at commits only present locally. When what's being tagged isn't a branch, any branch will do
+func (g *GitRepo) ensurePushed(ctx context.Context) error {
+	log := zerolog.Ctx(ctx)
+
+	log.Info().Msgf("fetching %v to check %v has been pushed", g.remote, g.targetName())
+	err := g.repo.FetchContext(ctx, &gogit.FetchOptions{
+		RemoteName: g.remote,
+		Auth:       g.auth,
+	})
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("error fetching %v: %w", g.remote, explainHostKeyError(err))
+	}
+
+	// anything cached was computed before the fetch, which may have brought in new tags too
+	g.tagsByPrefix = nil
+	g.ancestryWalkers = nil
+	g.nodeIndex = nil
+
+	branch, err := g.targetBranch()
+	if err != nil {
+		return err
+	}
+
+	if branch == "" {
+		containing, err := g.allowedRemoteBranchContaining(ctx, []string{"**"})
+		if err != nil {
+			return err
+		}
+		if containing == "" {
+			return fmt.Errorf("%w: no branch of %v contains %v. Push it, or set --%v=false", ErrNotPushed, g.remote, g.targetName(), config.RequirePushed)
+		}
+		return nil
+	}
+
+	remoteBranch, err := g.repo.Reference(plumbing.NewRemoteReferenceName(g.remote, branch), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return fmt.Errorf("%w: %v doesn't exist on %v. Push it, or set --%v=false", ErrNotPushed, branch, g.remote, config.RequirePushed)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %v/%v: %w", g.remote, branch, err)
+	}
+
+	target, err := g.targetHash()
+	if err != nil {
+		return err
+	}
+	pushed, err := g.IsAncestor(ctx, target.String(), remoteBranch.Hash().String())
+	if err != nil {
+		return fmt.Errorf("error checking if %v/%v contains %v: %w", g.remote, branch, g.targetName(), err)
+	}
+	if !pushed {
+		return fmt.Errorf("%w: %v/%v doesn't contain %v. Push it, or set --%v=false", ErrNotPushed, g.remote, branch, g.targetName(), config.RequirePushed)
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package bot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureCleanWorktree(t *testing.T) {
+	commit := testCommit{Message: "feat: one", Files: []string{"foo", "bar"}}
+
+	t.Run("clean", func(t *testing.T) {
+		_, g := newMemoryGitRepo(t, commit)
+		g.requireCleanWorktree = true
+		require.NoError(t, g.EnsureReadyToTag(newCtxWithLog(t)))
+	})
+
+	t.Run("untracked ignored", func(t *testing.T) {
+		repo, g := newMemoryGitRepo(t, commit)
+		g.requireCleanWorktree = true
+		f, err := repo.fs.Create("build-output")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		require.NoError(t, g.EnsureReadyToTag(newCtxWithLog(t)))
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		repo, g := newMemoryGitRepo(t, commit)
+		g.requireCleanWorktree = true
+		f, err := repo.fs.Create("foo")
+		require.NoError(t, err)
+		_, err = f.Write([]byte("changed"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		err = g.EnsureReadyToTag(newCtxWithLog(t))
+		require.ErrorIs(t, err, ErrDirtyWorktree)
+		require.ErrorContains(t, err, "foo")
+	})
+
+	t.Run("staged", func(t *testing.T) {
+		repo, g := newMemoryGitRepo(t, commit)
+		g.requireCleanWorktree = true
+		w, err := repo.repo.Worktree()
+		require.NoError(t, err)
+		_, err = w.Remove("bar")
+		require.NoError(t, err)
+
+		require.ErrorIs(t, g.EnsureReadyToTag(newCtxWithLog(t)), ErrDirtyWorktree)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		repo, g := newMemoryGitRepo(t, commit)
+		require.NoError(t, repo.fs.Remove("foo"))
+
+		require.NoError(t, g.EnsureReadyToTag(newCtxWithLog(t)))
+	})
+
+	t.Run("explicit ref", func(t *testing.T) {
+		dir := t.TempDir()
+		repo, err := gogit.PlainInit(dir, false)
+		require.NoError(t, err)
+		w, err := repo.Worktree()
+		require.NoError(t, err)
+		createCommits(t, repo, w.Filesystem, testCommit{Message: "feat: one", Files: []string{"foo"}})
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "foo"), []byte("changed"), 0644))
+
+		g, err := NewGitRepo(GitRepoConfig{Path: dir, RequireCleanWorktree: true, NoPush: true})
+		require.NoError(t, err)
+		require.ErrorIs(t, g.EnsureReadyToTag(newCtxWithLog(t)), ErrDirtyWorktree)
+
+		g, err = NewGitRepo(GitRepoConfig{Path: dir, RequireCleanWorktree: true, NoPush: true, Ref: "HEAD"})
+		require.NoError(t, err)
+		require.NoError(t, g.EnsureReadyToTag(newCtxWithLog(t)))
+	})
+}
+
+func TestEnsurePushed(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	// clones a new repo from a remote with a single commit on master
+	newClone := func(t *testing.T) (string, *gogit.Repository, *gogit.Repository) {
+		t.Helper()
+
+		srcDir := t.TempDir()
+		src, err := gogit.PlainInit(srcDir, false)
+		require.NoError(t, err)
+		w, err := src.Worktree()
+		require.NoError(t, err)
+		createCommits(t, src, w.Filesystem, testCommit{Message: "feat: one", Files: []string{"foo"}})
+
+		dir := t.TempDir()
+		clone, err := gogit.PlainClone(dir, false, &gogit.CloneOptions{URL: "file://" + srcDir})
+		require.NoError(t, err)
+		return dir, clone, src
+	}
+
+	newRepo := func(t *testing.T, dir string) *GitRepo {
+		t.Helper()
+
+		g, err := NewGitRepo(GitRepoConfig{
+			Path:          dir,
+			Remote:        "origin",
+			RequirePushed: true,
+		})
+		require.NoError(t, err)
+		return g
+	}
+
+	t.Run("pushed", func(t *testing.T) {
+		dir, _, _ := newClone(t)
+		require.NoError(t, newRepo(t, dir).EnsureReadyToTag(newCtxWithLog(t)))
+	})
+
+	t.Run("local commit", func(t *testing.T) {
+		dir, clone, _ := newClone(t)
+		w, err := clone.Worktree()
+		require.NoError(t, err)
+		createCommits(t, clone, w.Filesystem, testCommit{Message: "feat: two", Files: []string{"foo"}})
+
+		err = newRepo(t, dir).EnsureReadyToTag(newCtxWithLog(t))
+		require.ErrorIs(t, err, ErrNotPushed)
+		require.ErrorContains(t, err, "origin/master doesn't contain HEAD")
+
+		// not needed when nothing will be pushed
+		g, err := NewGitRepo(GitRepoConfig{Path: dir, Remote: "origin", RequirePushed: true, NoPush: true})
+		require.NoError(t, err)
+		require.NoError(t, g.EnsureReadyToTag(newCtxWithLog(t)))
+
+		// but still is when tagging an explicit commit
+		g, err = NewGitRepo(GitRepoConfig{Path: dir, Remote: "origin", RequirePushed: true, Ref: "HEAD"})
+		require.NoError(t, err)
+		require.ErrorIs(t, g.EnsureReadyToTag(newCtxWithLog(t)), ErrNotPushed)
+	})
+
+	t.Run("fetched tags", func(t *testing.T) {
+		dir, _, src := newClone(t)
+		g := newRepo(t, dir)
+		ctx := newCtxWithLog(t)
+
+		latest, err := g.GetLatestTag(ctx, "")
+		require.NoError(t, err)
+		require.Nil(t, latest)
+
+		w, err := src.Worktree()
+		require.NoError(t, err)
+		createCommits(t, src, w.Filesystem, testCommit{Message: "feat: two", Files: []string{"foo"}, Tags: []string{"v0.1.0"}})
+
+		require.NoError(t, g.EnsureReadyToTag(ctx))
+		tags, err := g.GetTags(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, tags, 1)
+		require.Equal(t, "v0.1.0", tags[0].TagName)
+	})
+
+	t.Run("local branch", func(t *testing.T) {
+		dir, clone, _ := newClone(t)
+		w, err := clone.Worktree()
+		require.NoError(t, err)
+		require.NoError(t, w.Checkout(&gogit.CheckoutOptions{Branch: "refs/heads/feature", Create: true}))
+
+		err = newRepo(t, dir).EnsureReadyToTag(newCtxWithLog(t))
+		require.ErrorIs(t, err, ErrNotPushed)
+		require.ErrorContains(t, err, "feature doesn't exist on origin")
+	})
+
+	t.Run("detached", func(t *testing.T) {
+		dir, clone, _ := newClone(t)
+		head, err := clone.Head()
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte(head.Hash().String()+"\n"), 0644))
+
+		require.NoError(t, newRepo(t, dir).EnsureReadyToTag(newCtxWithLog(t)))
+	})
+}
@@ -0,0 +1,155 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	gogit "github.com/go-git/go-git/v5"
+	gogitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/rs/zerolog"
+)
+
+// PushTags pushes the tags created by this run to the remote, leaving any other local tags alone. When the remote
+// supports it the push is atomic, so either every tag is created or none are. On error, the tags that didn't reach the
+// remote are returned so they can be rolled back
+func (g *GitRepo) PushTags(ctx context.Context, created []CreatedTag) ([]CreatedTag, error) {
+	log := zerolog.Ctx(ctx)
+
+	if len(created) == 0 {
+		return nil, nil
+	}
+
+	refSpecs := []gogitconfig.RefSpec{}
+	for _, tag := range created {
+		name := plumbing.NewTagReferenceName(tag.Name)
+		spec := gogitconfig.RefSpec(name + ":" + name)
+		// moving an existing tag (such as "latest") needs forcing. Version tags are never moved, so a release that
+		// already exists on the remote is rejected rather than overwritten
+		if tag.Previous != nil {
+			spec = "+" + spec
+		}
+		refSpecs = append(refSpecs, spec)
+	}
+
+	url, err := g.pushedURL()
+	if err != nil {
+		return created, err
+	}
+	atomic, err := g.supportsAtomicPush(ctx, url)
+	if err != nil {
+		return created, fmt.Errorf("error checking if %v supports atomic pushes: %w", g.remote, explainHostKeyError(err))
+	}
+	if !atomic {
+		log.Warn().Msgf("%v doesn't support atomic pushes, so if it rejects any tag the others may still be created", g.remote)
+	}
+
+	err = g.repo.PushContext(ctx, &gogit.PushOptions{
+		RemoteName: g.remote,
+		RemoteURL:  g.pushURL,
+		RefSpecs:   refSpecs,
+		Auth:       g.auth,
+		Atomic:     atomic,
+	})
+	if err == nil || errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return nil, nil
+	}
+	err = fmt.Errorf("error pushing: %w", explainHostKeyError(err))
+	if atomic {
+		return created, err
+	}
+
+	// some tags may have been created regardless, and those are now published
+	unpushed, listErr := g.unpushedTags(ctx, url, created)
+	if listErr != nil {
+		log.Err(listErr).Msg("error checking which tags reached the remote, assuming none did")
+		return created, err
+	}
+	for _, tag := range created {
+		if !slices.Contains(unpushed, tag) {
+			log.Warn().Msgf("%v reached %v, keeping it", tag.Name, g.remote)
+		}
+	}
+	return unpushed, err
+}
+
+// unpushedTags lists the remote's tags, returning the created tags it doesn't have at the same object as the local repo
+func (g *GitRepo) unpushedTags(ctx context.Context, url string, created []CreatedTag) ([]CreatedTag, error) {
+	remote := gogit.NewRemote(g.repo.Storer, &gogitconfig.RemoteConfig{
+		Name: g.remote,
+		URLs: []string{url},
+	})
+	refs, err := remote.ListContext(ctx, &gogit.ListOptions{Auth: g.auth})
+	if err != nil {
+		return nil, fmt.Errorf("error listing %v: %w", g.remote, explainHostKeyError(err))
+	}
+	remoteHashes := map[plumbing.ReferenceName]plumbing.Hash{}
+	for _, ref := range refs {
+		remoteHashes[ref.Name()] = ref.Hash()
+	}
+
+	unpushed := []CreatedTag{}
+	for _, tag := range created {
+		name := plumbing.NewTagReferenceName(tag.Name)
+		local, err := g.repo.Reference(name, false)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %v: %w", tag.Name, err)
+		}
+		if hash, ok := remoteHashes[name]; !ok || hash != local.Hash() {
+			unpushed = append(unpushed, tag)
+		}
+	}
+	return unpushed, nil
+}
+
+// pushedURL returns the url tags are pushed to, the same one go-git pushes to
+func (g *GitRepo) pushedURL() (string, error) {
+	if g.pushURL != "" {
+		return g.pushURL, nil
+	}
+
+	remote, err := g.repo.Remote(g.remote)
+	if err != nil {
+		return "", fmt.Errorf("error getting remote: %w", err)
+	}
+	urls := remote.Config().URLs
+	if len(urls) == 0 {
+		return "", fmt.Errorf("%v has no url", g.remote)
+	}
+	return urls[len(urls)-1], nil
+}
+
+// supportsAtomicPush asks the remote whether it advertises the atomic capability. go-git quietly drops the request for
+// an atomic push when it isn't advertised, and doesn't expose the capabilities of the session it pushes over, so this
+// costs a session of its own (an extra connection, and authentication) just to read them
+func (g *GitRepo) supportsAtomicPush(ctx context.Context, url string) (supported bool, err error) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return false, fmt.Errorf("error parsing url: %w", err)
+	}
+	c, err := client.NewClient(endpoint)
+	if err != nil {
+		return false, fmt.Errorf("error creating client: %w", err)
+	}
+	session, err := c.NewReceivePackSession(endpoint, g.auth)
+	if err != nil {
+		return false, fmt.Errorf("error connecting: %w", err)
+	}
+	defer func() {
+		if closeErr := session.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("error closing connection: %w", closeErr)
+		}
+	}()
+
+	refs, err := session.AdvertisedReferencesContext(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error reading advertised references: %w", err)
+	}
+
+	return refs.Capabilities.Supports(capability.Atomic), nil
+}
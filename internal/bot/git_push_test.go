@@ -0,0 +1,126 @@
+package bot
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushTags(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	// creates a repo whose origin is a bare repo with an update hook rejecting the "rejected" tag
+	newRepo := func(t *testing.T, advertiseAtomic bool) (context.Context, *GitRepo, *gogit.Repository) {
+		t.Helper()
+
+		bareDir := t.TempDir()
+		bare, err := gogit.PlainInit(bareDir, true)
+		require.NoError(t, err)
+		hook := "#!/bin/sh\nif [ \"$1\" = refs/tags/rejected ]; then exit 1; fi\n"
+		require.NoError(t, os.MkdirAll(filepath.Join(bareDir, "hooks"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(bareDir, "hooks", "update"), []byte(hook), 0755))
+		if !advertiseAtomic {
+			out, err := exec.Command("git", "-C", bareDir, "config", "receive.advertiseAtomic", "false").CombinedOutput()
+			require.NoError(t, err, string(out))
+		}
+
+		dir := t.TempDir()
+		src, err := gogit.PlainInit(dir, false)
+		require.NoError(t, err)
+		w, err := src.Worktree()
+		require.NoError(t, err)
+		createCommits(t, src, w.Filesystem, testCommit{Message: "feat: one", Files: []string{"foo"}})
+		out, err := exec.Command("git", "-C", dir, "remote", "add", "origin", "file://"+bareDir).CombinedOutput()
+		require.NoError(t, err, string(out))
+
+		g, err := NewGitRepo(GitRepoConfig{Path: dir, Remote: "origin"})
+		require.NoError(t, err)
+
+		ctx := newCtxWithLog(t)
+		url, err := g.pushedURL()
+		require.NoError(t, err)
+		supported, err := g.supportsAtomicPush(ctx, url)
+		require.NoError(t, err)
+		require.Equal(t, advertiseAtomic, supported)
+
+		return ctx, g, bare
+	}
+
+	landed := func(t *testing.T, bare *gogit.Repository) []string {
+		t.Helper()
+
+		tags := []string{}
+		for _, tag := range []string{"v1.0.0", "rejected"} {
+			if _, err := bare.Tag(tag); err == nil {
+				tags = append(tags, tag)
+			}
+		}
+		return tags
+	}
+
+	t.Run("atomic", func(t *testing.T) {
+		ctx, g, bare := newRepo(t, true)
+		created, err := g.MakeTagsAtTarget(ctx, "v1.0.0", "rejected")
+		require.NoError(t, err)
+		unpushed, err := g.PushTags(ctx, created)
+		require.Error(t, err)
+		require.Equal(t, created, unpushed)
+		require.Empty(t, landed(t, bare))
+	})
+
+	t.Run("atomic not supported", func(t *testing.T) {
+		ctx, g, bare := newRepo(t, false)
+		created, err := g.MakeTagsAtTarget(ctx, "v1.0.0", "rejected")
+		require.NoError(t, err)
+		unpushed, err := g.PushTags(ctx, created)
+		require.Error(t, err)
+		require.Equal(t, []string{"v1.0.0"}, landed(t, bare))
+
+		// the tag that made it is published, so only the other one is left to roll back
+		require.Equal(t, created[1:], unpushed)
+	})
+
+	t.Run("only moves latest", func(t *testing.T) {
+		ctx, g, bare := newRepo(t, true)
+		created, err := g.MakeTagsAtTarget(ctx, "v1.0.0", "latest")
+		require.NoError(t, err)
+		_, err = g.PushTags(ctx, created)
+		require.NoError(t, err)
+
+		// a published version is never replaced, while latest is
+		_, err = g.MakeTagsAtTarget(ctx, "v1.0.0", "latest")
+		require.ErrorIs(t, err, gogit.ErrTagExists)
+
+		created, err = g.MakeTagsAtTarget(ctx, "v1.1.0", "latest")
+		require.NoError(t, err)
+		require.Nil(t, created[0].Previous)
+		require.NotNil(t, created[1].Previous)
+		_, err = g.PushTags(ctx, created)
+		require.NoError(t, err)
+
+		want, err := g.repo.Tag("latest")
+		require.NoError(t, err)
+		got, err := bare.Tag("latest")
+		require.NoError(t, err)
+		require.Equal(t, want.Hash(), got.Hash())
+	})
+
+	t.Run("only created tags", func(t *testing.T) {
+		// a stale local tag the remote would reject doesn't hold up the release
+		ctx, g, bare := newRepo(t, true)
+		_, err := g.MakeTagsAtTarget(ctx, "rejected")
+		require.NoError(t, err)
+
+		created, err := g.MakeTagsAtTarget(ctx, "v1.0.0")
+		require.NoError(t, err)
+		_, err = g.PushTags(ctx, created)
+		require.NoError(t, err)
+		require.Equal(t, []string{"v1.0.0"}, landed(t, bare))
+	})
+}
@@ -1,8 +1,11 @@
 package bot
 
 import (
+	"fmt"
 	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -10,7 +13,10 @@ import (
 	"github.com/go-git/go-billy/v5/memfs"
 	gogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
 	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/nicjohnson145/hlp"
+	"github.com/nicjohnson145/tagbot/internal/config"
 	"github.com/stretchr/testify/require"
 )
 
@@ -35,7 +41,7 @@ func TestGetFilesForCommit(t *testing.T) {
 		require.NoError(t, err)
 
 		r := GitRepo{}
-		got, err := r.getFilesForCommit(c)
+		got, err := r.getFilesForCommit(c, nil)
 		require.NoError(t, err)
 		require.ElementsMatch(t, got, []string{"dir/foo", "dir/bar"})
 	})
@@ -63,7 +69,7 @@ func TestGetFilesForCommit(t *testing.T) {
 		require.NoError(t, err)
 
 		r := GitRepo{}
-		got, err := r.getFilesForCommit(c)
+		got, err := r.getFilesForCommit(c, nil)
 		require.NoError(t, err)
 		require.ElementsMatch(t, got, []string{"dir/bar", "dir/baz"})
 	})
@@ -155,7 +161,7 @@ func TestGetFilesForCommit(t *testing.T) {
 			c, err := repo.CommitObject(addCommit)
 			require.NoError(t, err)
 
-			got, err := g.getFilesForCommit(c)
+			got, err := g.getFilesForCommit(c, nil)
 			require.NoError(t, err)
 			require.ElementsMatch(t, got, []string{"foo"})
 		})
@@ -164,7 +170,7 @@ func TestGetFilesForCommit(t *testing.T) {
 			c, err := repo.CommitObject(editCommit)
 			require.NoError(t, err)
 
-			got, err := g.getFilesForCommit(c)
+			got, err := g.getFilesForCommit(c, nil)
 			require.NoError(t, err)
 			require.ElementsMatch(t, got, []string{"foo"})
 		})
@@ -173,7 +179,7 @@ func TestGetFilesForCommit(t *testing.T) {
 			c, err := repo.CommitObject(renameCommit)
 			require.NoError(t, err)
 
-			got, err := g.getFilesForCommit(c)
+			got, err := g.getFilesForCommit(c, nil)
 			require.NoError(t, err)
 			require.ElementsMatch(t, got, []string{"foo", "bar"})
 		})
@@ -182,9 +188,458 @@ func TestGetFilesForCommit(t *testing.T) {
 			c, err := repo.CommitObject(deleteCommit)
 			require.NoError(t, err)
 
-			got, err := g.getFilesForCommit(c)
+			got, err := g.getFilesForCommit(c, nil)
 			require.NoError(t, err)
 			require.ElementsMatch(t, got, []string{"bar"})
 		})
 	})
 }
+
+func TestGetFilesForMerge(t *testing.T) {
+	repo := newMemoryRepo(
+		t,
+		testCommit{
+			Message: "some message",
+			Files:   []string{"base"},
+		},
+	)
+	repo.Checkout(t, "feature", true)
+	repo.MakeCommits(t, testCommit{
+		Message: "feature message",
+		Files:   []string{"feature"},
+	})
+	repo.Checkout(t, "master", false)
+	repo.MakeCommits(t, testCommit{
+		Message: "mainline message",
+		Files:   []string{"main"},
+	})
+	mergeHash := repo.Merge(t, "feature", "Merge branch 'feature'")
+
+	merge, err := repo.repo.CommitObject(mergeHash)
+	require.NoError(t, err)
+
+	g := GitRepo{}
+
+	t.Run("against first parent", func(t *testing.T) {
+		got, err := g.getFilesForCommit(merge, nil)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"feature"}, got)
+	})
+
+	t.Run("against all parents", func(t *testing.T) {
+		got, err := g.getFilesForMerge(merge, nil)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{}, got)
+	})
+
+	t.Run("merged commits", func(t *testing.T) {
+		got, err := mergedCommits(commitgraph.NewObjectCommitNodeIndex(repo.repo.Storer), merge)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		require.Equal(t, "feature message", got[0].Message)
+	})
+}
+
+func TestGetLatestTag(t *testing.T) {
+	repo := newMemoryRepo(
+		t,
+		testCommit{
+			Message: "some message",
+			Tags:    []string{"v0.1.0", "foo/v0.1.0"},
+			Files:   []string{"base"},
+		},
+		testCommit{
+			Message: "some message",
+			Tags:    []string{"v0.2.0"},
+			Files:   []string{"base"},
+		},
+	)
+	repo.Checkout(t, "unmerged", true)
+	repo.MakeCommits(t, testCommit{
+		Message: "some message",
+		Tags:    []string{"v1.0.0", "foo/v1.0.0"},
+		Files:   []string{"base"},
+	})
+	repo.Checkout(t, "master", false)
+
+	ctx := newCtxWithLog(t)
+
+	got, err := repo.GetLatestTag(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, "v0.2.0", got.TagName)
+
+	got, err = repo.GetLatestTag(ctx, "foo")
+	require.NoError(t, err)
+	require.Equal(t, "v0.1.0", got.TagName)
+
+	got, err = repo.GetLatestTag(ctx, "bar")
+	require.NoError(t, err)
+	require.Nil(t, got)
+}
+
+func TestGetTagsAtTarget(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	// a clone that didn't fetch the tags its origin has at HEAD
+	newClone := func(t *testing.T) string {
+		t.Helper()
+
+		srcDir := t.TempDir()
+		src, err := gogit.PlainInit(srcDir, false)
+		require.NoError(t, err)
+		w, err := src.Worktree()
+		require.NoError(t, err)
+		hashes := createCommits(
+			t,
+			src,
+			w.Filesystem,
+			testCommit{Message: "feat: one", Files: []string{"foo"}, Tags: []string{"v0.1.0"}},
+			testCommit{Message: "feat: two", Files: []string{"foo"}, Tags: []string{"foo/v0.2.0"}},
+		)
+		// and an annotated tag, which is listed at the tag object before being peeled to the commit
+		_, err = src.CreateTag("v0.2.0", hashes[1], &gogit.CreateTagOptions{
+			Message: "release",
+			Tagger:  &object.Signature{Name: "tagbot", Email: "tagbot@example.com", When: time.Now()},
+		})
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		_, err = gogit.PlainClone(dir, false, &gogit.CloneOptions{URL: "file://" + srcDir, Tags: gogit.NoTags})
+		require.NoError(t, err)
+		return dir
+	}
+
+	t.Run("includes the remote's when pushing", func(t *testing.T) {
+		g, err := NewGitRepo(GitRepoConfig{Path: newClone(t), Remote: "origin"})
+		require.NoError(t, err)
+		ctx := newCtxWithLog(t)
+
+		tags, err := g.GetTagsAtTarget(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, tags, 1)
+		require.Equal(t, "v0.2.0", tags[0].TagName)
+
+		tags, err = g.GetTagsAtTarget(ctx, "foo")
+		require.NoError(t, err)
+		require.Len(t, tags, 1)
+		require.Equal(t, "v0.2.0", tags[0].TagName)
+	})
+
+	t.Run("local only without pushing", func(t *testing.T) {
+		g, err := NewGitRepo(GitRepoConfig{Path: newClone(t), Remote: "origin", NoPush: true})
+		require.NoError(t, err)
+
+		tags, err := g.GetTagsAtTarget(newCtxWithLog(t), "foo")
+		require.NoError(t, err)
+		require.Empty(t, tags)
+	})
+}
+
+func TestIsAncestorSkewedClock(t *testing.T) {
+	// the middle commit claims to be older than its parent, as happens with rebases and badly set clocks
+	first := nextCommitTime()
+	repo := newMemoryRepo(
+		t,
+		testCommit{Message: "feat: one", Tags: []string{"v0.1.0"}, Files: []string{"foo"}},
+		testCommit{Message: "fix: two", Files: []string{"foo"}, When: first.Add(-24 * time.Hour)},
+		testCommit{Message: "fix: three", Files: []string{"foo"}},
+	)
+	ctx := newCtxWithLog(t)
+
+	got, err := repo.GetLatestTag(ctx, "")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	require.Equal(t, "v0.1.0", got.TagName)
+
+	head, err := repo.repo.Head()
+	require.NoError(t, err)
+	isAncestor, err := repo.IsAncestor(ctx, got.Hash, head.Hash().String())
+	require.NoError(t, err)
+	require.True(t, isAncestor)
+}
+
+func TestNewGitRepo(t *testing.T) {
+	dir := t.TempDir()
+	src, err := gogit.PlainInit(dir, false)
+	require.NoError(t, err)
+	w, err := src.Worktree()
+	require.NoError(t, err)
+	hashes := createCommits(
+		t,
+		src,
+		w.Filesystem,
+		testCommit{Message: "feat: one", Files: []string{"sub/dir/foo"}},
+	)
+
+	newRepo := func(path string, ref string) (*GitRepo, error) {
+		return NewGitRepo(GitRepoConfig{
+			Path:       path,
+			Ref:        ref,
+			AuthMethod: config.AuthKindToken.String(),
+			AuthToken:  "some-token",
+		})
+	}
+
+	t.Run("subdirectory", func(t *testing.T) {
+		repo, err := newRepo(filepath.Join(dir, "sub", "dir"), "")
+		require.NoError(t, err)
+
+		target, err := repo.targetHash()
+		require.NoError(t, err)
+		require.Equal(t, hashes[0], target)
+
+		root, err := repo.Root()
+		require.NoError(t, err)
+		require.Equal(t, dir, root)
+	})
+
+	t.Run("linked worktree", func(t *testing.T) {
+		worktreeDir := filepath.Join(t.TempDir(), "linked")
+		out, err := exec.Command("git", "-C", dir, "worktree", "add", "-b", "linked", worktreeDir).CombinedOutput()
+		require.NoError(t, err, string(out))
+
+		repo, err := newRepo(filepath.Join(worktreeDir, "sub"), "")
+		require.NoError(t, err)
+
+		target, err := repo.targetHash()
+		require.NoError(t, err)
+		require.Equal(t, hashes[0], target)
+
+		root, err := repo.Root()
+		require.NoError(t, err)
+		require.Equal(t, worktreeDir, root)
+	})
+
+	t.Run("unknown ref", func(t *testing.T) {
+		_, err := newRepo(dir, "does-not-exist")
+		require.Error(t, err)
+	})
+
+	t.Run("bare without pushing", func(t *testing.T) {
+		bareDir := t.TempDir()
+		bare, err := gogit.PlainClone(bareDir, true, &gogit.CloneOptions{
+			URL: "file://" + dir,
+		})
+		require.NoError(t, err)
+
+		repo, err := NewGitRepo(GitRepoConfig{
+			Path:   bareDir,
+			Ref:    "refs/heads/master",
+			NoPush: true,
+		})
+		require.NoError(t, err)
+
+		root, err := repo.Root()
+		require.NoError(t, err)
+		require.Empty(t, root)
+
+		bot := NewTagbot(TagbotConfig{
+			MonorepoConfig: &config.MonoRepoConfig{
+				Components: map[string]config.MonoRepoComponent{
+					"core": {
+						Name:           "core",
+						ChangeSetGlobs: []string{"**/*"},
+						Prefix:         hlp.Ptr(""),
+						MaintainLatest: hlp.Ptr(false),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(false),
+					},
+				},
+			},
+			Repo:   repo,
+			NoPush: true,
+		})
+		require.NoError(t, bot.Run(newCtxWithLog(t)))
+
+		_, err = bare.Tag("v0.0.1")
+		require.NoError(t, err)
+		_, err = src.Tag("v0.0.1")
+		require.ErrorIs(t, err, gogit.ErrTagNotFound)
+	})
+}
+
+func TestCommitGraph(t *testing.T) {
+	dir := t.TempDir()
+	src, err := gogit.PlainInit(dir, false)
+	require.NoError(t, err)
+	w, err := src.Worktree()
+	require.NoError(t, err)
+	hashes := createCommits(
+		t,
+		src,
+		w.Filesystem,
+		testCommit{Message: "feat: one", Files: []string{"foo"}},
+		testCommit{Message: "feat: two", Files: []string{"foo"}},
+		testCommit{Message: "feat: three", Files: []string{"foo"}},
+	)
+	out, err := exec.Command("git", "-C", dir, "commit-graph", "write", "--reachable").CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	repo, err := NewGitRepo(GitRepoConfig{
+		Path:           dir,
+		NoPush:         true,
+		UseCommitGraph: true,
+	})
+	require.NoError(t, err)
+
+	ctx := newCtxWithLog(t)
+
+	// nodes read from the commit-graph carry a generation number, unlike those read from commit objects
+	node, err := repo.commitNodes(ctx).Get(hashes[2])
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), node.Generation())
+
+	isAncestor, err := repo.IsAncestor(ctx, hashes[0].String(), hashes[2].String())
+	require.NoError(t, err)
+	require.True(t, isAncestor)
+
+	isAncestor, err = repo.IsAncestor(ctx, hashes[2].String(), hashes[1].String())
+	require.NoError(t, err)
+	require.False(t, isAncestor)
+}
+
+func TestEnsureHistory(t *testing.T) {
+	cloneShallow := func(t *testing.T, opts gogit.CloneOptions, commits ...testCommit) *gogit.Repository {
+		t.Helper()
+
+		srcDir := t.TempDir()
+		src, err := gogit.PlainInit(srcDir, false)
+		require.NoError(t, err)
+		w, err := src.Worktree()
+		require.NoError(t, err)
+		createCommits(t, src, w.Filesystem, commits...)
+
+		opts.URL = "file://" + srcDir
+		clone, err := gogit.PlainClone(t.TempDir(), false, &opts)
+		require.NoError(t, err)
+		return clone
+	}
+
+	newShallowClone := func(t *testing.T) *gogit.Repository {
+		t.Helper()
+
+		return cloneShallow(
+			t,
+			gogit.CloneOptions{Depth: 1, Tags: gogit.NoTags},
+			testCommit{Message: "feat: one", Files: []string{"foo"}},
+			testCommit{Message: "feat: two", Files: []string{"foo"}, Tags: []string{"v0.1.0"}},
+			testCommit{Message: "feat: three", Files: []string{"foo"}},
+			testCommit{Message: "feat: four", Files: []string{"foo"}},
+		)
+	}
+
+	// a long history, with the tag too far back for the first deepening to reach the start of history
+	longHistory := func() []testCommit {
+		commits := []testCommit{}
+		for i := 1; i <= 120; i++ {
+			commit := testCommit{Message: fmt.Sprintf("fix: change %v", i), Files: []string{"foo"}}
+			if i == 100 {
+				commit.Tags = []string{"v0.1.0"}
+			}
+			commits = append(commits, commit)
+		}
+		return commits
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		g := &GitRepo{
+			remote:      "origin",
+			shallowMode: config.ShallowKindFail,
+			repo:        newShallowClone(t),
+		}
+
+		require.ErrorIs(t, g.EnsureHistory(newCtxWithLog(t), []string{""}), ErrShallowRepository)
+	})
+
+	t.Run("deepen", func(t *testing.T) {
+		g := &GitRepo{
+			remote:      "origin",
+			shallowMode: config.ShallowKindDeepen,
+			repo:        newShallowClone(t),
+		}
+
+		ctx := newCtxWithLog(t)
+		require.NoError(t, g.EnsureHistory(ctx, []string{""}))
+
+		shallow, err := g.isShallow()
+		require.NoError(t, err)
+		require.False(t, shallow)
+
+		tag, err := g.GetLatestTag(ctx, "")
+		require.NoError(t, err)
+		require.Equal(t, "v0.1.0", tag.TagName)
+	})
+
+	t.Run("fail with the latest tag in the clone", func(t *testing.T) {
+		g := &GitRepo{
+			remote:      "origin",
+			shallowMode: config.ShallowKindFail,
+			repo:        cloneShallow(t, gogit.CloneOptions{Depth: 30, Tags: gogit.AllTags}, longHistory()...),
+		}
+
+		ctx := newCtxWithLog(t)
+		require.NoError(t, g.EnsureHistory(ctx, []string{""}))
+
+		tag, err := g.GetLatestTag(ctx, "")
+		require.NoError(t, err)
+		require.Equal(t, "v0.1.0", tag.TagName)
+	})
+
+	t.Run("fail without any tags", func(t *testing.T) {
+		g := &GitRepo{
+			remote:      "origin",
+			shallowMode: config.ShallowKindFail,
+			repo: cloneShallow(
+				t,
+				gogit.CloneOptions{Depth: 1},
+				testCommit{Message: "feat: one", Files: []string{"foo"}},
+				testCommit{Message: "feat: two", Files: []string{"foo"}},
+			),
+		}
+
+		require.ErrorIs(t, g.EnsureHistory(newCtxWithLog(t), []string{""}), ErrShallowRepository)
+	})
+
+	for _, strategy := range []config.WalkKind{config.WalkKindAll, config.WalkKindFirstParent, config.WalkKindTopological} {
+		t.Run("deepen part way then run "+strategy.String(), func(t *testing.T) {
+			clone := cloneShallow(t, gogit.CloneOptions{Depth: 1, Tags: gogit.NoTags}, longHistory()...)
+			g := &GitRepo{
+				remote:      "origin",
+				shallowMode: config.ShallowKindDeepen,
+				repo:        clone,
+			}
+			bot := NewTagbot(TagbotConfig{
+				MonorepoConfig: &config.MonoRepoConfig{
+					Components: map[string]config.MonoRepoComponent{
+						"core": {
+							Name:           "core",
+							ChangeSetGlobs: []string{"**/*"},
+							Prefix:         hlp.Ptr(""),
+							MaintainLatest: hlp.Ptr(false),
+							LatestName:     hlp.Ptr("latest"),
+							NoV:            hlp.Ptr(false),
+							AlwaysPatch:    hlp.Ptr(false),
+						},
+					},
+				},
+				Repo:         &unitTestRepo{IRepo: g, repo: clone},
+				WalkStrategy: strategy,
+			})
+
+			require.NoError(t, bot.Run(newCtxWithLog(t)))
+			mustHaveTags(t, &unitTestRepo{IRepo: g, repo: clone}, []string{"v0.1.0", "v0.1.1"})
+
+			// only as much history as needed was fetched
+			shallow, err := g.isShallow()
+			require.NoError(t, err)
+			require.True(t, shallow)
+		})
+	}
+
+	t.Run("not shallow", func(t *testing.T) {
+		repo := newMemoryRepo(t, testCommit{Message: "feat: one", Files: []string{"foo"}})
+		require.NoError(t, repo.EnsureHistory(newCtxWithLog(t), []string{""}))
+	})
+}
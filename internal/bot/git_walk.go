@@ -0,0 +1,365 @@
+package bot
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
+)
+
+// commitHeap is a max-heap of commits ordered by committer time, newest first
+type commitHeap []*object.Commit
+
+func (h commitHeap) Len() int           { return len(h) }
+func (h commitHeap) Less(i, j int) bool { return h[i].Committer.When.After(h[j].Committer.When) }
+func (h commitHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *commitHeap) Push(x any)        { *h = append(*h, x.(*object.Commit)) }
+func (h *commitHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+func (h commitHeap) newest() *object.Commit {
+	return h[0]
+}
+
+// nodeHeap is a max-heap of commit nodes ordered by generation, highest first, then by committer time, newest first.
+// Nodes without a generation sort above those with one, as they can only be newer than the commit-graph file
+type nodeHeap []commitgraph.CommitNode
+
+func (h nodeHeap) Len() int { return len(h) }
+func (h nodeHeap) Less(i, j int) bool {
+	if gi, gj := generation(h[i]), generation(h[j]); gi != gj {
+		return gi > gj
+	}
+	return h[i].CommitTime().After(h[j].CommitTime())
+}
+func (h nodeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *nodeHeap) Push(x any)   { *h = append(*h, x.(commitgraph.CommitNode)) }
+func (h *nodeHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+func (h nodeHeap) newest() commitgraph.CommitNode {
+	return h[0]
+}
+
+// maxWalkLead is how many commits the history of a commit being asked about is walked for each commit walked in the
+// history of the tip
+const maxWalkLead = 8
+
+// ancestryWalker incrementally walks the history of a single commit, answering whether other commits are part of that
+// history. Walking stops as soon as the commit being asked about is found, so repeated questions (i.e while walking a
+// log) only ever walk the history once. When backed by a commit-graph file, walking also stops once every unvisited
+// ancestor has a lower generation than the commit being asked about, and no commit objects need to be read at all.
+// Without generations, the history of the commit being asked about is walked alongside, as none of its own ancestors
+// can lead back to it, so the walk is bounded by where the two histories meet rather than the start of history.
+// Committer times only decide which side is walked next, they're never relied on for the answer, as clocks are too
+// often skewed (rebases, cherry-picks, badly set CI clocks)
+type ancestryWalker struct {
+	tip     commitgraph.CommitNode
+	queue   nodeHeap
+	seen    map[plumbing.Hash]bool
+	outside map[plumbing.Hash]bool
+	parents parentCache
+	// paths counts how many of the commits left to walk each commit has a known path down to, as found by the last walk
+	// alongside another commit's history. A commit with a path to all of them can't be reached from any of them
+	paths map[plumbing.Hash]int
+}
+
+// newAncestryWalker creates a walker for the tip's history, sharing already read parents with any other walkers given
+// the same cache. A nil cache starts a new one
+func newAncestryWalker(tip commitgraph.CommitNode, parents parentCache) *ancestryWalker {
+	if parents == nil {
+		parents = parentCache{}
+	}
+	return &ancestryWalker{
+		tip:     tip,
+		queue:   nodeHeap{tip},
+		seen:    map[plumbing.Hash]bool{tip.ID(): true},
+		outside: map[plumbing.Hash]bool{},
+		parents: parents,
+	}
+}
+
+// Contains reports if the commit is the tip, or one of its ancestors
+func (a *ancestryWalker) Contains(node commitgraph.CommitNode) (bool, error) {
+	if a.seen[node.ID()] {
+		return true, nil
+	}
+	if a.outside[node.ID()] || a.queue.Len() == 0 || a.paths[node.ID()] == a.queue.Len() {
+		return false, nil
+	}
+
+	// an ancestor always has a lower generation than its descendants, which rules out most unrelated commits without
+	// walking anything. Only commits read from a commit-graph file have a generation though
+	if hasGeneration(node) && hasGeneration(a.tip) {
+		if node.Generation() >= a.tip.Generation() {
+			return false, nil
+		}
+		return a.walkToGeneration(node)
+	}
+
+	contains, err := a.walkAlongside(node)
+	if err != nil {
+		return false, err
+	}
+	if !contains {
+		a.outside[node.ID()] = true
+	}
+	return contains, nil
+}
+
+// walkToGeneration walks the tip's history until the node is found, or nothing left to walk can reach its generation
+func (a *ancestryWalker) walkToGeneration(node commitgraph.CommitNode) (bool, error) {
+	for a.queue.Len() > 0 && !a.seen[node.ID()] {
+		if hasGeneration(a.queue.newest()) && a.queue.newest().Generation() < node.Generation() {
+			break
+		}
+
+		if err := a.expand(heap.Pop(&a.queue).(commitgraph.CommitNode)); err != nil {
+			return false, err
+		}
+	}
+	return a.seen[node.ID()], nil
+}
+
+// walkAlongside walks the tip's history and the node's own history together. Anything in the tip's history that the
+// node's history also reaches is one of the node's ancestors, so can't lead to the node, and is set aside for later
+// questions rather than walked. The node isn't an ancestor once nothing is left to walk from the tip
+func (a *ancestryWalker) walkAlongside(node commitgraph.CommitNode) (bool, error) {
+	// each commit known to be below the node, along with the child it was reached from
+	below := map[plumbing.Hash]plumbing.Hash{}
+	own := nodeHeap{}
+	push := func(n commitgraph.CommitNode) error {
+		parents, err := a.parents.of(n)
+		if err != nil {
+			return err
+		}
+		for _, parent := range parents {
+			if _, ok := below[parent.ID()]; !ok {
+				below[parent.ID()] = n.ID()
+				heap.Push(&own, parent)
+			}
+		}
+		return nil
+	}
+	if err := push(node); err != nil {
+		return false, err
+	}
+
+	setAside := []commitgraph.CommitNode{}
+	defer func() {
+		for _, n := range setAside {
+			heap.Push(&a.queue, n)
+		}
+	}()
+
+	lead := 0
+	for a.queue.Len() > 0 && !a.seen[node.ID()] {
+		// keep both sides at around the same point in history, so they meet as soon as possible. Times can't be trusted
+		// though, so the node's side never gets too far ahead, which bounds all the questions asked of a tip by the size
+		// of its history
+		if own.Len() > 0 && lead < maxWalkLead && own.newest().CommitTime().After(a.queue.newest().CommitTime()) {
+			lead++
+			if err := push(heap.Pop(&own).(commitgraph.CommitNode)); err != nil {
+				return false, err
+			}
+			continue
+		}
+
+		lead = 0
+		current := heap.Pop(&a.queue).(commitgraph.CommitNode)
+		if _, ok := below[current.ID()]; ok {
+			setAside = append(setAside, current)
+			continue
+		}
+		if err := a.expand(current); err != nil {
+			return false, err
+		}
+	}
+	if a.seen[node.ID()] {
+		return true, nil
+	}
+
+	// Everything left to walk is below the node. Commits along the way down to them are usually asked about next, as
+	// they're the node's ancestors, and share those paths
+	a.paths = map[plumbing.Hash]int{}
+	for _, n := range setAside {
+		for id := below[n.ID()]; id != node.ID(); id = below[id] {
+			a.paths[id]++
+		}
+	}
+	return false, nil
+}
+
+// expand queues the unseen parents of a commit in the tip's history
+func (a *ancestryWalker) expand(current commitgraph.CommitNode) error {
+	a.paths = nil
+	parents, err := a.parents.of(current)
+	if err != nil {
+		return err
+	}
+	for _, parent := range parents {
+		if !a.seen[parent.ID()] {
+			a.seen[parent.ID()] = true
+			heap.Push(&a.queue, parent)
+		}
+	}
+	return nil
+}
+
+// parentCache holds the parents of already walked commits, as reading them means decoding commit objects when there's
+// no commit-graph
+type parentCache map[plumbing.Hash][]commitgraph.CommitNode
+
+func (p parentCache) of(node commitgraph.CommitNode) ([]commitgraph.CommitNode, error) {
+	if parents, ok := p[node.ID()]; ok {
+		return parents, nil
+	}
+
+	parents := []commitgraph.CommitNode{}
+	err := node.ParentNodes().ForEach(func(parent commitgraph.CommitNode) error {
+		parents = append(parents, parent)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking parents of %v: %w", node.ID(), err)
+	}
+	p[node.ID()] = parents
+	return parents, nil
+}
+
+func hasGeneration(node commitgraph.CommitNode) bool {
+	gen := node.Generation()
+	return gen != 0 && gen != math.MaxUint64
+}
+
+// generation returns the generation of the node, or the highest possible one if it doesn't have one
+func generation(node commitgraph.CommitNode) uint64 {
+	if !hasGeneration(node) {
+		return math.MaxUint64
+	}
+	return node.Generation()
+}
+
+// topologicalOrder returns every commit reachable from the tip such that no commit comes before any of its children,
+// breaking ties by committer time. A commit can't be placed until all of its children are known, so the whole history is
+// read up front, regardless of how much of it the caller ends up looking at. Much like git, commits on the boundary of a
+// shallow clone are treated as if they had no parents
+func topologicalOrder(tip *object.Commit, boundary map[plumbing.Hash]bool) ([]*object.Commit, error) {
+	// first discover the whole graph, counting how many children each commit has
+	children := map[plumbing.Hash]int{}
+	discovered := map[plumbing.Hash]bool{tip.Hash: true}
+	pending := []*object.Commit{tip}
+	for len(pending) > 0 {
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if boundary[current.Hash] {
+			continue
+		}
+
+		err := current.Parents().ForEach(func(parent *object.Commit) error {
+			children[parent.Hash]++
+			if !discovered[parent.Hash] {
+				discovered[parent.Hash] = true
+				pending = append(pending, parent)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error walking parents of %v: %w", current.Hash, err)
+		}
+	}
+
+	// then emit commits once all their children have been emitted
+	ordered := []*object.Commit{}
+	ready := commitHeap{tip}
+	for ready.Len() > 0 {
+		current := heap.Pop(&ready).(*object.Commit)
+		ordered = append(ordered, current)
+		if boundary[current.Hash] {
+			continue
+		}
+
+		err := current.Parents().ForEach(func(parent *object.Commit) error {
+			children[parent.Hash]--
+			if children[parent.Hash] == 0 {
+				heap.Push(&ready, parent)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error walking parents of %v: %w", current.Hash, err)
+		}
+	}
+
+	return ordered, nil
+}
+
+// mergedCommits returns the commits a merge brought in, i.e those reachable from any of its non-first parents, but not
+// from its first parent. Commits are returned newest first
+func mergedCommits(nodes commitgraph.CommitNodeIndex, merge *object.Commit) ([]*object.Commit, error) {
+	mainline, err := merge.Parent(0)
+	if err != nil {
+		return nil, fmt.Errorf("error getting first parent: %w", err)
+	}
+	mainlineNode, err := nodes.Get(mainline.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("error getting first parent node: %w", err)
+	}
+	mainlineWalker := newAncestryWalker(mainlineNode, nil)
+
+	merged := []*object.Commit{}
+	seen := map[plumbing.Hash]bool{}
+	queue := commitHeap{}
+	err = merge.Parents().ForEach(func(parent *object.Commit) error {
+		if parent.Hash != mainline.Hash && !seen[parent.Hash] {
+			seen[parent.Hash] = true
+			heap.Push(&queue, parent)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking parents: %w", err)
+	}
+
+	for queue.Len() > 0 {
+		current := heap.Pop(&queue).(*object.Commit)
+
+		currentNode, err := nodes.Get(current.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("error getting node for %v: %w", current.Hash, err)
+		}
+		onMainline, err := mainlineWalker.Contains(currentNode)
+		if err != nil {
+			return nil, err
+		}
+		if onMainline {
+			// everything behind this commit is already part of the mainline as well
+			continue
+		}
+		merged = append(merged, current)
+
+		err = current.Parents().ForEach(func(parent *object.Commit) error {
+			if !seen[parent.Hash] {
+				seen[parent.Hash] = true
+				heap.Push(&queue, parent)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error walking parents of %v: %w", current.Hash, err)
+		}
+	}
+
+	return merged, nil
+}
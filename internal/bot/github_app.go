@@ -0,0 +1,142 @@
+package bot
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const (
+	// githubAppTokenUsername is the basic auth username GitHub expects alongside an installation token
+	githubAppTokenUsername = "x-access-token"
+
+	// GitHub rejects app JWTs that expire more than 10 minutes out, and ones issued in the future, so backdate them
+	// slightly to allow for clock drift
+	githubAppJWTLifetime = 9 * time.Minute
+	githubAppJWTBackdate = time.Minute
+
+	githubAPITimeout = 30 * time.Second
+)
+
+type githubInstallationToken struct {
+	Token string `json:"token"`
+}
+
+// githubAppToken mints an installation token for the app, which unlike the workflow's own GITHUB_TOKEN can trigger
+// other workflows with the tags it pushes
+func githubAppToken(conf GitRepoConfig, now time.Time) (string, error) {
+	if conf.GithubAppID == "" || conf.GithubAppInstallationID == "" {
+		return "", fmt.Errorf("both an app id and installation id are required for github app auth")
+	}
+
+	key, err := githubAppPrivateKey(conf)
+	if err != nil {
+		return "", err
+	}
+
+	signed, err := githubAppJWT(conf.GithubAppID, key, now)
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("%v/app/installations/%v/access_tokens", strings.TrimSuffix(conf.GithubAPIURL, "/"), conf.GithubAppInstallationID)
+	req, err := http.NewRequest(http.MethodPost, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("error building installation token request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+signed)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	client := &http.Client{Timeout: githubAPITimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting installation token: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading installation token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("error requesting installation token, got %v: %v", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var token githubInstallationToken
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("error decoding installation token response: %w", err)
+	}
+	if token.Token == "" {
+		return "", fmt.Errorf("installation token response contained no token")
+	}
+
+	return token.Token, nil
+}
+
+// githubAppJWT signs the token authenticating as the app itself, which is only good for minting installation tokens
+func githubAppJWT(appID string, key *rsa.PrivateKey, now time.Time) (string, error) {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		return "", fmt.Errorf("error creating jwt signer: %w", err)
+	}
+
+	signed, err := jwt.Signed(signer).Claims(jwt.Claims{
+		Issuer:   appID,
+		IssuedAt: jwt.NewNumericDate(now.Add(-githubAppJWTBackdate)),
+		Expiry:   jwt.NewNumericDate(now.Add(githubAppJWTLifetime)),
+	}).Serialize()
+	if err != nil {
+		return "", fmt.Errorf("error signing jwt: %w", err)
+	}
+
+	return signed, nil
+}
+
+// githubAppPrivateKey parses the app's private key, given directly or by path, as GitHub's PKCS #1 or as PKCS #8
+func githubAppPrivateKey(conf GitRepoConfig) (*rsa.PrivateKey, error) {
+	content := []byte(conf.GithubAppPrivateKey)
+	if len(content) == 0 {
+		if conf.GithubAppPrivateKeyPath == "" {
+			return nil, fmt.Errorf("a private key is required for github app auth")
+		}
+		c, err := os.ReadFile(conf.GithubAppPrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading github app private key: %w", err)
+		}
+		content = c
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("github app private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing github app private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("github app private key must be an RSA key")
+	}
+	return key, nil
+}
@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGithubAppToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	now := time.Now()
+
+	// stands in for the GitHub API, only handing out a token to a correctly signed request for installation 42
+	newServer := func(t *testing.T) *httptest.Server {
+		t.Helper()
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /api/v3/app/installations/42/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+			signed, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok {
+				http.Error(w, `{"message":"missing bearer"}`, http.StatusUnauthorized)
+				return
+			}
+			token, err := jwt.ParseSigned(signed, []jose.SignatureAlgorithm{jose.RS256})
+			if err != nil {
+				http.Error(w, `{"message":"malformed jwt"}`, http.StatusUnauthorized)
+				return
+			}
+			claims := jwt.Claims{}
+			if err := token.Claims(&key.PublicKey, &claims); err != nil {
+				http.Error(w, `{"message":"bad signature"}`, http.StatusUnauthorized)
+				return
+			}
+			if err := claims.ValidateWithLeeway(jwt.Expected{Issuer: "1234", Time: now}, 0); err != nil {
+				http.Error(w, `{"message":"bad claims"}`, http.StatusUnauthorized)
+				return
+			}
+			if claims.Expiry.Time().Sub(claims.IssuedAt.Time()) > 10*time.Minute {
+				http.Error(w, `{"message":"expiry too far in the future"}`, http.StatusUnauthorized)
+				return
+			}
+
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"ghs_installation","expires_at":"2030-01-01T00:00:00Z"}`))
+		})
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	t.Run("key from path", func(t *testing.T) {
+		server := newServer(t)
+		keyPath := filepath.Join(t.TempDir(), "app.pem")
+		require.NoError(t, os.WriteFile(keyPath, []byte(keyPEM), 0600))
+
+		got, err := githubAppToken(GitRepoConfig{
+			GithubAppID:             "1234",
+			GithubAppInstallationID: "42",
+			GithubAppPrivateKeyPath: keyPath,
+			GithubAPIURL:            server.URL + "/api/v3/",
+		}, now)
+		require.NoError(t, err)
+		require.Equal(t, "ghs_installation", got)
+	})
+
+	t.Run("pkcs8 key", func(t *testing.T) {
+		server := newServer(t)
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		require.NoError(t, err)
+
+		got, err := githubAppToken(GitRepoConfig{
+			GithubAppID:             "1234",
+			GithubAppInstallationID: "42",
+			GithubAppPrivateKey:     string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+			GithubAPIURL:            server.URL + "/api/v3",
+		}, now)
+		require.NoError(t, err)
+		require.Equal(t, "ghs_installation", got)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		server := newServer(t)
+
+		_, err := githubAppToken(GitRepoConfig{
+			GithubAppID:             "5678",
+			GithubAppInstallationID: "42",
+			GithubAppPrivateKey:     keyPEM,
+			GithubAPIURL:            server.URL + "/api/v3",
+		}, now)
+		require.ErrorContains(t, err, "401")
+		require.ErrorContains(t, err, "bad claims")
+	})
+
+	t.Run("missing installation", func(t *testing.T) {
+		_, err := githubAppToken(GitRepoConfig{
+			GithubAppID:         "1234",
+			GithubAppPrivateKey: keyPEM,
+		}, now)
+		require.ErrorContains(t, err, "installation id")
+	})
+
+	t.Run("used by token auth", func(t *testing.T) {
+		server := newServer(t)
+
+		g := &GitRepo{}
+		auth, err := g.tokenAuth(GitRepoConfig{
+			AuthToken:               "workflow-token",
+			GithubAppID:             "1234",
+			GithubAppInstallationID: "42",
+			GithubAppPrivateKey:     keyPEM,
+			GithubAPIURL:            server.URL + "/api/v3",
+		})
+		require.NoError(t, err)
+		require.Equal(t, githubAppTokenUsername, auth.Username)
+		require.Equal(t, "ghs_installation", auth.Password)
+	})
+}
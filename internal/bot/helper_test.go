@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -19,10 +20,42 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// ciEnv is the environment CI sets that tagbot reads, which would otherwise leak into tests ran in CI
+var ciEnv = []string{
+	"GITHUB_EVENT_PATH",
+	"GITHUB_REF_TYPE",
+	"GITHUB_REF_NAME",
+	"CI_COMMIT_BRANCH",
+}
+
+func TestMain(m *testing.M) {
+	for _, key := range ciEnv {
+		_ = os.Unsetenv(key)
+	}
+	os.Exit(m.Run())
+}
+
+var (
+	testClockMu sync.Mutex
+	testClock   = time.Now()
+)
+
+// nextCommitTime hands out strictly increasing commit times, as commits made within the same second would otherwise be
+// indistinguishable when walking history by committer time
+func nextCommitTime() time.Time {
+	testClockMu.Lock()
+	defer testClockMu.Unlock()
+
+	testClock = testClock.Add(time.Second)
+	return testClock
+}
+
 type testCommit struct {
 	Message string
 	Files   []string
 	Tags    []string
+	// When overrides the commit time, for simulating skewed clocks
+	When time.Time
 }
 
 type unitTestRepo struct {
@@ -31,6 +64,7 @@ type unitTestRepo struct {
 	fs   billy.Filesystem
 
 	pushCalled bool
+	pushErr    error
 }
 
 func (u *unitTestRepo) MakeCommits(t *testing.T, commits ...testCommit) []plumbing.Hash {
@@ -39,9 +73,80 @@ func (u *unitTestRepo) MakeCommits(t *testing.T, commits ...testCommit) []plumbi
 	return createCommits(t, u.repo, u.fs, commits...)
 }
 
-func (u *unitTestRepo) PushTags(ctx context.Context) error {
+func (u *unitTestRepo) PushTags(ctx context.Context, created []CreatedTag) ([]CreatedTag, error) {
 	u.pushCalled = true
-	return nil
+	if u.pushErr != nil {
+		return created, u.pushErr
+	}
+	return nil, nil
+}
+
+// Checkout switches the worktree to the given branch, optionally creating it from the current HEAD
+func (u *unitTestRepo) Checkout(t *testing.T, branch string, create bool) {
+	t.Helper()
+
+	w, err := u.repo.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, w.Checkout(&gogit.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(branch),
+		Create: create,
+	}))
+}
+
+// Merge creates a merge commit of the given branch into the current HEAD, taking the branch's version of any file it
+// changed
+func (u *unitTestRepo) Merge(t *testing.T, branch string, message string) plumbing.Hash {
+	t.Helper()
+
+	head, err := u.repo.Head()
+	require.NoError(t, err)
+	headCommit, err := u.repo.CommitObject(head.Hash())
+	require.NoError(t, err)
+
+	branchRef, err := u.repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	require.NoError(t, err)
+	branchCommit, err := u.repo.CommitObject(branchRef.Hash())
+	require.NoError(t, err)
+
+	bases, err := headCommit.MergeBase(branchCommit)
+	require.NoError(t, err)
+	require.Len(t, bases, 1)
+	baseTree, err := bases[0].Tree()
+	require.NoError(t, err)
+
+	w, err := u.repo.Worktree()
+	require.NoError(t, err)
+
+	files, err := branchCommit.Files()
+	require.NoError(t, err)
+	require.NoError(t, files.ForEach(func(f *object.File) error {
+		if baseFile, err := baseTree.File(f.Name); err == nil && baseFile.Hash == f.Hash {
+			return nil
+		}
+
+		content, err := f.Contents()
+		require.NoError(t, err)
+		require.NoError(t, u.fs.MkdirAll(filepath.Dir(f.Name), 0755))
+		out, err := u.fs.OpenFile(f.Name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+		require.NoError(t, err)
+		_, err = io.Copy(out, strings.NewReader(content))
+		require.NoError(t, err)
+		require.NoError(t, out.Close())
+		_, err = w.Add(f.Name)
+		return err
+	}))
+
+	hash, err := w.Commit(message, &gogit.CommitOptions{
+		Author: &object.Signature{
+			Name:  "tagbot",
+			Email: "tagbot@example.com",
+			When:  nextCommitTime(),
+		},
+		Parents: []plumbing.Hash{headCommit.Hash, branchCommit.Hash},
+	})
+	require.NoError(t, err)
+
+	return hash
 }
 
 func newMemoryRepo(t *testing.T, commits ...testCommit) *unitTestRepo {
@@ -64,6 +169,14 @@ func newMemoryRepo(t *testing.T, commits ...testCommit) *unitTestRepo {
 	return testRepo
 }
 
+// newMemoryGitRepo is newMemoryRepo, also returning the underlying GitRepo for tests poking at its internals
+func newMemoryGitRepo(t *testing.T, commits ...testCommit) (*unitTestRepo, *GitRepo) {
+	t.Helper()
+
+	repo := newMemoryRepo(t, commits...)
+	return repo, repo.IRepo.(*GitRepo)
+}
+
 func createCommits(t *testing.T, repo *gogit.Repository, fs billy.Filesystem, commits ...testCommit) []plumbing.Hash {
 	t.Helper()
 
@@ -96,11 +209,15 @@ func createCommits(t *testing.T, repo *gogit.Repository, fs billy.Filesystem, co
 		}
 
 		// create a commit with the change set
+		when := commit.When
+		if when.IsZero() {
+			when = nextCommitTime()
+		}
 		hash, err := w.Commit(commit.Message, &gogit.CommitOptions{
 			Author: &object.Signature{
 				Name:  "tagbot",
 				Email: "tagbot@example.com",
-				When:  time.Now(),
+				When:  when,
 			},
 		})
 		require.NoError(t, err)
@@ -0,0 +1,144 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nicjohnson145/hlp"
+	"github.com/nicjohnson145/tagbot/internal/config"
+)
+
+var (
+	ErrLintError = errors.New("commit message failed lint")
+)
+
+var trailerRegex = regexp.MustCompile(`^(?P<key>[A-Za-z0-9-]+): (?P<value>.*)$`)
+
+type LintViolation struct {
+	Rule     string
+	Severity config.LintSeverity
+	Message  string
+}
+
+func (l LintViolation) String() string {
+	return fmt.Sprintf("%v: %v", l.Rule, l.Message)
+}
+
+type commitMessage struct {
+	Header   string
+	Subject  string
+	Scopes   []string
+	Breaking bool
+	Lines    []string
+	Body     string
+	Trailers map[string][]string
+}
+
+func parseCommitMessage(message string) commitMessage {
+	lines := strings.Split(strings.TrimRight(message, "\n"), "\n")
+	parts := hlp.ExtractNamedMatches(messagesRegex, messagesRegex.FindStringSubmatch(message))
+
+	msg := commitMessage{
+		Header:   lines[0],
+		Subject:  parts["subject"],
+		Scopes:   ScopesFromCommitMessage(message),
+		Breaking: parts["breaking"] != "",
+		Lines:    lines,
+		Body:     strings.TrimSpace(strings.Join(lines[1:], "\n")),
+		Trailers: map[string][]string{},
+	}
+
+	// Trailers live in the last paragraph of the body
+	if msg.Body != "" {
+		paragraphs := strings.Split(msg.Body, "\n\n")
+		for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+			trailer := hlp.ExtractNamedMatches(trailerRegex, trailerRegex.FindStringSubmatch(line))
+			if len(trailer) == 0 {
+				continue
+			}
+			msg.Trailers[trailer["key"]] = append(msg.Trailers[trailer["key"]], trailer["value"])
+		}
+	}
+
+	return msg
+}
+
+// LintCommitMessage checks the message against each enabled rule, returning every violation found
+func LintCommitMessage(conf *config.LintConfig, message string) []LintViolation {
+	violations := []LintViolation{}
+	if conf == nil {
+		return violations
+	}
+
+	msg := parseCommitMessage(message)
+	violation := func(name string, rule config.LintRule, format string, args ...any) {
+		violations = append(violations, LintViolation{
+			Rule:     name,
+			Severity: rule.Severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if rule := conf.HeaderMaxLength; rule != nil {
+		if length := utf8.RuneCountInString(msg.Header); length > rule.Length {
+			violation("header-max-length", rule.LintRule, "header is %v characters long, must be at most %v", length, rule.Length)
+		}
+	}
+
+	if rule := conf.ScopeRequired; rule != nil && len(msg.Scopes) == 0 {
+		violation("scope-required", *rule, "header must include a scope, i.e 'feat(scope): ...'")
+	}
+
+	if rule := conf.ScopeEnum; rule != nil {
+		for _, scope := range msg.Scopes {
+			if !slices.Contains(rule.Scopes, scope) {
+				violation("scope-enum", rule.LintRule, "scope '%v' is not one of %v", scope, rule.Scopes)
+			}
+		}
+	}
+
+	if rule := conf.SubjectNoTrailingPeriod; rule != nil && strings.HasSuffix(strings.TrimSpace(msg.Subject), ".") {
+		violation("subject-no-trailing-period", *rule, "subject must not end with a period")
+	}
+
+	if rule := conf.SubjectLowerCase; rule != nil {
+		if first, _ := utf8.DecodeRuneInString(msg.Subject); unicode.IsUpper(first) {
+			violation("subject-lower-case", *rule, "subject must start with a lower case letter")
+		}
+	}
+
+	if rule := conf.BreakingChangeBody; rule != nil && msg.Breaking && msg.Body == "" {
+		violation("breaking-change-body", *rule, "breaking changes must include a body describing the break")
+	}
+
+	if rule := conf.BodyLeadingBlank; rule != nil && len(msg.Lines) > 1 && strings.TrimSpace(msg.Lines[1]) != "" {
+		violation("body-leading-blank", *rule, "body must be separated from the header by a blank line")
+	}
+
+	if rule := conf.TrailerRequired; rule != nil {
+		// git treats trailer keys case insensitively, so "refs:" satisfies a required "Refs" trailer
+		values := []string{}
+		found := false
+		for key, keyValues := range msg.Trailers {
+			if strings.EqualFold(key, rule.Trailer) {
+				values = append(values, keyValues...)
+				found = true
+			}
+		}
+		if !found {
+			violation("trailer-required", rule.LintRule, "message must end with a '%v: <value>' trailer", rule.Trailer)
+		} else if rule.Pattern != "" {
+			pattern := regexp.MustCompile(rule.Pattern)
+			if !slices.ContainsFunc(values, pattern.MatchString) {
+				violation("trailer-required", rule.LintRule, "'%v' trailer must match '%v', got %v", rule.Trailer, rule.Pattern, values)
+			}
+		}
+	}
+
+	return violations
+}
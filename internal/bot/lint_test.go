@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/nicjohnson145/tagbot/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintCommitMessage(t *testing.T) {
+	t.Parallel()
+
+	errorRule := config.LintRule{Severity: config.LintSeverityError}
+	warningRule := config.LintRule{Severity: config.LintSeverityWarning}
+
+	testData := []struct {
+		name     string
+		config   *config.LintConfig
+		message  string
+		expected []LintViolation
+	}{
+		{
+			name:     "no config",
+			message:  "feat: Do a thing.",
+			expected: []LintViolation{},
+		},
+		{
+			name: "clean message",
+			config: &config.LintConfig{
+				HeaderMaxLength:         &config.LengthLintRule{LintRule: errorRule, Length: 72},
+				ScopeEnum:               &config.ScopesLintRule{LintRule: errorRule, Scopes: []string{"api"}},
+				ScopeRequired:           &errorRule,
+				SubjectNoTrailingPeriod: &errorRule,
+				SubjectLowerCase:        &errorRule,
+				BreakingChangeBody:      &errorRule,
+				TrailerRequired:         &config.TrailerLintRule{LintRule: errorRule, Trailer: "Refs", Pattern: `^PROJ-\d+$`},
+				BodyLeadingBlank:        &errorRule,
+			},
+			message: dedent.Dedent(`
+				feat(api)!: do a thing
+
+				Callers must now pass a context.
+
+				Refs: PROJ-123
+			`[1:]),
+			expected: []LintViolation{},
+		},
+		{
+			name: "header too long",
+			config: &config.LintConfig{
+				HeaderMaxLength: &config.LengthLintRule{LintRule: errorRule, Length: 10},
+			},
+			message: "feat: do a thing",
+			expected: []LintViolation{
+				{Rule: "header-max-length", Severity: config.LintSeverityError, Message: "header is 16 characters long, must be at most 10"},
+			},
+		},
+		{
+			name: "scopes",
+			config: &config.LintConfig{
+				ScopeEnum:     &config.ScopesLintRule{LintRule: warningRule, Scopes: []string{"api"}},
+				ScopeRequired: &errorRule,
+			},
+			message: "feat(web): do a thing",
+			expected: []LintViolation{
+				{Rule: "scope-enum", Severity: config.LintSeverityWarning, Message: "scope 'web' is not one of [api]"},
+			},
+		},
+		{
+			name: "missing scope",
+			config: &config.LintConfig{
+				ScopeRequired: &errorRule,
+			},
+			message: "feat: do a thing",
+			expected: []LintViolation{
+				{Rule: "scope-required", Severity: config.LintSeverityError, Message: "header must include a scope, i.e 'feat(scope): ...'"},
+			},
+		},
+		{
+			name: "subject",
+			config: &config.LintConfig{
+				SubjectNoTrailingPeriod: &errorRule,
+				SubjectLowerCase:        &warningRule,
+			},
+			message: "feat: Do a thing.",
+			expected: []LintViolation{
+				{Rule: "subject-no-trailing-period", Severity: config.LintSeverityError, Message: "subject must not end with a period"},
+				{Rule: "subject-lower-case", Severity: config.LintSeverityWarning, Message: "subject must start with a lower case letter"},
+			},
+		},
+		{
+			name: "breaking change without body",
+			config: &config.LintConfig{
+				BreakingChangeBody: &errorRule,
+			},
+			message: "feat!: do a thing",
+			expected: []LintViolation{
+				{Rule: "breaking-change-body", Severity: config.LintSeverityError, Message: "breaking changes must include a body describing the break"},
+			},
+		},
+		{
+			name: "missing blank line",
+			config: &config.LintConfig{
+				BodyLeadingBlank: &errorRule,
+			},
+			message: dedent.Dedent(`
+				feat: do a thing
+				with a body
+			`[1:]),
+			expected: []LintViolation{
+				{Rule: "body-leading-blank", Severity: config.LintSeverityError, Message: "body must be separated from the header by a blank line"},
+			},
+		},
+		{
+			name: "missing trailer",
+			config: &config.LintConfig{
+				TrailerRequired: &config.TrailerLintRule{LintRule: errorRule, Trailer: "Refs"},
+			},
+			message: dedent.Dedent(`
+				feat: do a thing
+
+				Refs: PROJ-123 isn't a trailer when its not in the last paragraph
+
+				some more body
+			`[1:]),
+			expected: []LintViolation{
+				{Rule: "trailer-required", Severity: config.LintSeverityError, Message: "message must end with a 'Refs: <value>' trailer"},
+			},
+		},
+		{
+			name: "trailer in another case",
+			config: &config.LintConfig{
+				TrailerRequired: &config.TrailerLintRule{LintRule: errorRule, Trailer: "Refs", Pattern: `^PROJ-\d+$`},
+			},
+			message: dedent.Dedent(`
+				feat: do a thing
+
+				refs: PROJ-123
+			`[1:]),
+			expected: []LintViolation{},
+		},
+		{
+			name: "trailer wrong format",
+			config: &config.LintConfig{
+				TrailerRequired: &config.TrailerLintRule{LintRule: errorRule, Trailer: "Refs", Pattern: `^PROJ-\d+$`},
+			},
+			message: dedent.Dedent(`
+				feat: do a thing
+
+				Refs: OTHER-123
+			`[1:]),
+			expected: []LintViolation{
+				{Rule: "trailer-required", Severity: config.LintSeverityError, Message: "'Refs' trailer must match '^PROJ-\\d+$', got [OTHER-123]"},
+			},
+		},
+	}
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.expected, LintCommitMessage(tc.config, tc.message))
+		})
+	}
+}
@@ -2,8 +2,11 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"sort"
+	"strings"
 
 	"github.com/Masterminds/semver"
 	"github.com/bmatcuk/doublestar/v4"
@@ -14,24 +17,59 @@ import (
 	"github.com/rs/zerolog"
 )
 
+var (
+	ErrVersionConflict = errors.New("version conflicts with an existing tag")
+)
+
+const (
+	// releaseTrailer is the trailer that approves releasing manually released components
+	releaseTrailer = "Release"
+	// maxRevertDepth caps how many reverts of reverts are followed to find the scope of the original commit
+	maxRevertDepth = 10
+)
+
 type TagbotConfig struct {
-	MonorepoConfig *config.MonoRepoConfig
-	Repo           IRepo
-	DryRun         bool
+	MonorepoConfig             *config.MonoRepoConfig
+	LintConfig                 *config.LintConfig
+	AllowedGeneratedMessages   []config.GeneratedMessageKind
+	WalkStrategy               config.WalkKind
+	MergeClassification        config.MergeKind
+	Repo                       IRepo
+	DryRun                     bool
+	NoPush                     bool
+	AllowMultipleTagsPerCommit bool
+	AllowedBranches            []string
+	VersionConflictMode        config.VersionConflictKind
 }
 
 func NewTagbot(conf TagbotConfig) *Tagbot {
 	return &Tagbot{
-		monorepoConfig: conf.MonorepoConfig,
-		repo:           conf.Repo,
-		dryRun:         conf.DryRun,
+		monorepoConfig:             conf.MonorepoConfig,
+		lintConfig:                 conf.LintConfig,
+		allowedGeneratedMessages:   conf.AllowedGeneratedMessages,
+		walkStrategy:               conf.WalkStrategy,
+		mergeClassification:        conf.MergeClassification,
+		repo:                       conf.Repo,
+		dryRun:                     conf.DryRun,
+		noPush:                     conf.NoPush,
+		allowMultipleTagsPerCommit: conf.AllowMultipleTagsPerCommit,
+		allowedBranches:            conf.AllowedBranches,
+		versionConflictMode:        conf.VersionConflictMode,
 	}
 }
 
 type Tagbot struct {
-	monorepoConfig *config.MonoRepoConfig
-	repo           IRepo
-	dryRun         bool
+	monorepoConfig             *config.MonoRepoConfig
+	lintConfig                 *config.LintConfig
+	allowedGeneratedMessages   []config.GeneratedMessageKind
+	walkStrategy               config.WalkKind
+	mergeClassification        config.MergeKind
+	repo                       IRepo
+	dryRun                     bool
+	noPush                     bool
+	allowMultipleTagsPerCommit bool
+	allowedBranches            []string
+	versionConflictMode        config.VersionConflictKind
 }
 
 func (t *Tagbot) Run(ctx context.Context) error {
@@ -49,6 +87,30 @@ func (t *Tagbot) Run(ctx context.Context) error {
 	keys := hlp.Keys(t.monorepoConfig.Components)
 	sort.Strings(keys)
 
+	// make sure we have enough history to find each components latest tag
+	prefixes := []string{}
+	for _, key := range keys {
+		component := t.monorepoConfig.Components[key]
+		prefixes = append(prefixes, getPrefix(&component))
+	}
+	if err := t.repo.EnsureHistory(ctx, prefixes); err != nil {
+		return fmt.Errorf("error ensuring history: %w", err)
+	}
+
+	// releasing from the wrong branch can't be undone once pushed, but there's no harm in seeing what would happen
+	if err := t.repo.EnsureAllowedBranch(ctx, t.allowedBranches); err != nil {
+		if !t.dryRun || !errors.Is(err, ErrBranchNotAllowed) {
+			return fmt.Errorf("error checking branch: %w", err)
+		}
+		log.Warn().Err(err).Msg("DRYRUN: would refuse to tag")
+	}
+	if err := t.repo.EnsureReadyToTag(ctx); err != nil {
+		if !t.dryRun || !(errors.Is(err, ErrDirtyWorktree) || errors.Is(err, ErrNotPushed)) {
+			return fmt.Errorf("error running pre-flight checks: %w", err)
+		}
+		log.Warn().Err(err).Msg("DRYRUN: would refuse to tag")
+	}
+
 	// get the latest tag for each component, so we only have to walk the commit tree once
 	log.Info().Msg("getting latest tags by prefix")
 	latestTags := map[string]*Tag{}
@@ -69,14 +131,58 @@ func (t *Tagbot) Run(ctx context.Context) error {
 		latestTags[key] = mostRecent
 	}
 
-	// now walk the commits and figure out what our bumps should be, stopping once we've processed every prefix
+	// a component already released at the commit being tagged (such as when rerunning on the same commit) has nothing
+	// new to release, regardless of what its history says
+	released := map[string]string{}
+	if !t.allowMultipleTagsPerCommit {
+		for _, key := range keys {
+			component := t.monorepoConfig.Components[key]
+			prefix := getPrefix(&component)
+			tags, err := t.repo.GetTagsAtTarget(ctx, prefix)
+			if err != nil {
+				return fmt.Errorf("error getting tags at target: %w", err)
+			}
+			if len(tags) > 0 {
+				released[key] = joinPrefix(prefix, tags[0].TagName)
+			}
+		}
+	}
+
+	// now walk the commits and collect the ones relevant to each component, stopping once we've processed every prefix
 	log.Info().Msg("walking commit history")
 	bumpMap := hlp.MapFromSlice(keys, func(key string, _ int) (string, VersionBump) {
 		return key, VersionBumpIrrelevant
 	})
-	activeKeys := set.New(keys...)
+	relevantCommits := map[string][]*Commit{}
+	approved := map[string]bool{}
+	activeKeys := set.New(slices.DeleteFunc(slices.Clone(keys), func(key string) bool {
+		_, ok := released[key]
+		return ok
+	})...)
+	// When following first parents, the first released commit means the rest are as well. Otherwise the latest tag may be
+	// on a side branch that's reached before unreleased commits of the mainline, so each component tracks which commits
+	// yet to be seen could still be unreleased, and is done once there are none
+	firstParent := t.walkStrategy == config.WalkKindFirstParent
+	pending := map[string]*set.Set[string]{}
+	visited := set.New[string]()
+	logOpts := LogOptions{
+		Strategy:            t.walkStrategy,
+		MergeClassification: t.mergeClassification,
+		PathGlobs: func() [][]string {
+			globs := [][]string{}
+			for _, key := range activeKeys.AsSlice() {
+				component := t.monorepoConfig.Components[key]
+				globs = append(globs, component.ChangeSetGlobs)
+				if marker := releaseMarkerFile(&component); marker != "" {
+					globs = append(globs, []string{marker})
+				}
+			}
+			return globs
+		},
+	}
 	err := t.repo.ProcessLogWhere(
 		ctx,
+		logOpts,
 		func(_ *object.Commit) bool {
 			return activeKeys.Count() == 0
 		},
@@ -87,35 +193,93 @@ func (t *Tagbot) Run(ctx context.Context) error {
 				latestTag := latestTags[key]
 				component := t.monorepoConfig.Components[key]
 
-				// if the key has no latest tag, immediately process it (i.e on the latest commit) and remove it from the map
+				// if the key has no latest tag, immediately process it (i.e on the latest commit) and remove it from the map.
+				// Nothing has been released though, so a manually released component can be approved (and made relevant)
+				// by any commit in its history, and keeps being processed until it has been
 				if latestTag == nil {
-					relevant, err := t.commitRelevantToComponent(&component, commit)
-					if err != nil {
-						return false, err
+					if bumpMap[key] == VersionBumpIrrelevant {
+						relevant, err := t.commitRelevantToComponent(ctx, &component, commit)
+						if err != nil {
+							return false, err
+						}
+						if relevant {
+							bumpMap[key] = VersionBumpMinor
+						}
 					}
-					if relevant {
-						bumpMap[key] = VersionBumpMinor
+					for _, c := range append([]*Commit{commit}, commit.MergedCommits...) {
+						if t.approvesRelease(&component, c) {
+							approved[key] = true
+						}
+					}
+					if !manualRelease(&component) || (approved[key] && bumpMap[key] != VersionBumpIrrelevant) {
+						activeKeys.Remove(key)
 					}
-					activeKeys.Remove(key)
 					continue
 				}
 
-				// if we've reached the commit that the latest tag corrresponds to, we're done processing commits and the key should be removed from consideration
-				if commit.Hash == latestTag.Hash {
-					activeKeys.Remove(key)
+				if pending[key] == nil {
+					pending[key] = set.New(commit.Hash)
+				}
+				pending[key].Remove(commit.Hash)
+
+				// commits that are already part of the latest tag have been released, as has everything behind them
+				released := commit.Hash == latestTag.Hash
+				if !released {
+					var err error
+					released, err = t.repo.IsAncestor(ctx, commit.Hash, latestTag.Hash)
+					if err != nil {
+						return false, fmt.Errorf("error checking ancestry: %w", err)
+					}
+				}
+				if released {
+					if firstParent || pending[key].Count() == 0 {
+						activeKeys.Remove(key)
+					}
 					continue
 				}
+				if !firstParent {
+					for _, parent := range commit.ParentHashes {
+						if !visited.Contains(parent) {
+							pending[key].Add(parent)
+						}
+					}
+				}
 
-				// otherwise we havent gotten to its tag yet, so processthe commit
-				oldBump := bumpMap[key]
-				newBump, err := t.processCommit(ctx, &component, commit)
-				if err != nil {
-					return false, err
+				// otherwise we havent gotten to its tag yet, so hold onto the commit (and anything it merged) if it's
+				// relevant
+				for _, c := range append([]*Commit{commit}, commit.MergedCommits...) {
+					if c != commit {
+						released, err := t.repo.IsAncestor(ctx, c.Hash, latestTag.Hash)
+						if err != nil {
+							return false, fmt.Errorf("error checking ancestry: %w", err)
+						}
+						if released {
+							continue
+						}
+					}
+
+					// any unreleased commit can approve a release, relevant or not, so empty commits can be used to do so
+					if t.approvesRelease(&component, c) {
+						approved[key] = true
+					}
+
+					relevant, err := t.commitRelevantToComponent(ctx, &component, c)
+					if err != nil {
+						return false, err
+					}
+					if !relevant {
+						log.Trace().Msgf("commit %v determined not relevant to %v", c.ShortHash, component.Name)
+						continue
+					}
+					relevantCommits[key] = append(relevantCommits[key], c)
 				}
-				if newBump.Greater(oldBump) {
-					bumpMap[key] = newBump
+
+				// an unreleased root commit leaves nothing more to see
+				if !firstParent && pending[key].Count() == 0 {
+					activeKeys.Remove(key)
 				}
 			}
+			visited.Add(commit.Hash)
 			return true, nil
 		},
 	)
@@ -123,6 +287,17 @@ func (t *Tagbot) Run(ctx context.Context) error {
 		return fmt.Errorf("error walking commit list: %w", err)
 	}
 
+	// figure out what our bumps should be, ignoring any commits that were reverted within the same window
+	for _, key := range keys {
+		window := t.dropRevertedCommits(ctx, relevantCommits[key])
+		for _, commit := range window {
+			newBump := t.bumpForCommit(ctx, commit, window)
+			if newBump.Greater(bumpMap[key]) {
+				bumpMap[key] = newBump
+			}
+		}
+	}
+
 	makeTagString := func(component *config.MonoRepoComponent, version string) string {
 		prefix := ""
 		v := "v"
@@ -144,11 +319,17 @@ func (t *Tagbot) Run(ctx context.Context) error {
 
 	// now that we've got all our bumps, walk them again and do our "always patch" logic, log, and make our tags
 	tagMade := false
+	created := []CreatedTag{}
 	for _, key := range keys {
 		bump := bumpMap[key]
 		component := t.monorepoConfig.Components[key]
 		mostRecent := latestTags[key]
 
+		if tagName, ok := released[key]; ok {
+			log.Info().Msgf("decision for %v is already released, %v is on the commit being tagged", key, tagName)
+			continue
+		}
+
 		if bump != VersionBumpIrrelevant && VersionBumpPatch.Greater(bump) && component.AlwaysPatch != nil && *component.AlwaysPatch {
 			bump = VersionBumpPatch
 		}
@@ -172,19 +353,47 @@ func (t *Tagbot) Run(ctx context.Context) error {
 				}
 			}
 
-			wantTags := []string{
-				makeTagString(&component, newTag.String()),
+			// manually released components only compute what's pending until a commit approves releasing it
+			if manualRelease(&component) && !approved[key] {
+				msg := fmt.Sprintf("%v is pending release as %v, waiting for a commit with a '%v: true' trailer", key, makeTagString(&component, newTag.String()), releaseTrailer)
+				if marker := releaseMarkerFile(&component); marker != "" {
+					msg += fmt.Sprintf(" or changing %v", marker)
+				}
+				log.Info().Msg(msg)
+				continue
+			}
+
+			// a version that's already taken can never be released again, and one below a version released elsewhere
+			// would leave the released line out of order unless that's allowed
+			versionTag := makeTagString(&component, newTag.String())
+			if err := t.checkVersionConflict(ctx, getPrefix(&component), versionTag, &newTag); err != nil {
+				switch {
+				case t.versionConflictMode == config.VersionConflictKindSkip:
+					log.Warn().Err(err).Msgf("skipping %v", key)
+					continue
+				case t.dryRun && errors.Is(err, ErrVersionConflict):
+					log.Warn().Err(err).Msg("DRYRUN: would refuse to tag")
+				default:
+					t.rollback(ctx, created)
+					return fmt.Errorf("error checking %v for conflicts: %w", key, err)
+				}
 			}
+
+			movingTags := []string{}
 			if component.MaintainLatest != nil && *component.MaintainLatest {
-				wantTags = append(wantTags, makeLatest(&component))
+				movingTags = append(movingTags, makeLatest(&component))
 			}
+			wantTags := append([]string{versionTag}, movingTags...)
 
 			if t.dryRun {
 				log.Info().Msgf("DRYRUN: would create %v", wantTags)
 			} else {
 				tagMade = true
 				log.Info().Msgf("creating %v", wantTags)
-				if err := t.repo.MakeTagsAtHead(ctx, wantTags...); err != nil {
+				made, err := t.repo.MakeTagsAtTarget(ctx, versionTag, movingTags...)
+				created = append(created, made...)
+				if err != nil {
+					t.rollback(ctx, created)
 					return fmt.Errorf("error creating tag: %w", err)
 				}
 			}
@@ -194,9 +403,12 @@ func (t *Tagbot) Run(ctx context.Context) error {
 	if tagMade {
 		if t.dryRun {
 			log.Info().Msg("DRYRUN: would push tags")
+		} else if t.noPush {
+			log.Info().Msg("tags created locally, not pushing")
 		} else {
 			log.Info().Msgf("pushing tags")
-			if err := t.repo.PushTags(ctx); err != nil {
+			if unpushed, err := t.repo.PushTags(ctx, created); err != nil {
+				t.rollback(ctx, unpushed)
 				return fmt.Errorf("error pushing tags: %w", err)
 			}
 		}
@@ -207,23 +419,200 @@ func (t *Tagbot) Run(ctx context.Context) error {
 	return nil
 }
 
-func (t *Tagbot) processCommit(ctx context.Context, component *config.MonoRepoComponent, commit *Commit) (VersionBump, error) {
+// manualRelease reports if the component only releases once approved
+func manualRelease(component *config.MonoRepoComponent) bool {
+	return component.ReleaseMode != nil && *component.ReleaseMode == config.ReleaseKindManual
+}
+
+// releaseMarkerFile returns the file whose changes approve releasing the component, if it's released manually and has
+// one
+func releaseMarkerFile(component *config.MonoRepoComponent) string {
+	if !manualRelease(component) || component.ReleaseMarkerFile == nil {
+		return ""
+	}
+	return *component.ReleaseMarkerFile
+}
+
+// approvesRelease reports if the commit approves releasing a manually released component, either by a `Release: true`
+// trailer or by changing its release marker file
+func (t *Tagbot) approvesRelease(component *config.MonoRepoComponent, commit *Commit) bool {
+	if !manualRelease(component) {
+		return false
+	}
+
+	for key, values := range parseCommitMessage(commit.Message).Trailers {
+		if !strings.EqualFold(key, releaseTrailer) {
+			continue
+		}
+		if slices.ContainsFunc(values, func(value string) bool {
+			return strings.EqualFold(strings.TrimSpace(value), "true")
+		}) {
+			return true
+		}
+	}
+
+	marker := releaseMarkerFile(component)
+	return marker != "" && slices.Contains(commit.Files, marker)
+}
+
+// checkVersionConflict makes sure a new version for the prefix doesn't already exist, and unless the conflict mode allows
+// it, sorts above every existing one in the release line it's part of, including those that aren't reachable from the
+// commit being tagged, such as newer releases made on another branch. The release line of a patch is its major.minor,
+// and of a minor its major, so backporting fixes to an older line (v1.2.1 after v2.0.0) is fine, but releasing below a
+// line's newest version isn't
+func (t *Tagbot) checkVersionConflict(ctx context.Context, prefix string, tagName string, version *semver.Version) error {
+	tags, err := t.repo.GetTags(ctx, prefix)
+	if err != nil {
+		return fmt.Errorf("error getting tags: %w", err)
+	}
+
+	for _, tag := range tags {
+		if tag.Tag.Equal(version) {
+			return fmt.Errorf("%w: %v already exists as %v. Set --%v to skip it", ErrVersionConflict, tagName, joinPrefix(prefix, tag.TagName), config.VersionConflictMode)
+		}
+	}
+	if t.versionConflictMode == config.VersionConflictKindAllow {
+		return nil
+	}
+
+	// tags are sorted highest first, so the first one in the same line is the highest
+	for _, tag := range tags {
+		if !tag.Tag.GreaterThan(version) {
+			break
+		}
+		if sameReleaseLine(version, tag.Tag) {
+			return fmt.Errorf("%w: %v sorts below the existing %v. Set --%v to skip or allow it", ErrVersionConflict, tagName, joinPrefix(prefix, tag.TagName), config.VersionConflictMode)
+		}
+	}
+
+	return nil
+}
+
+// sameReleaseLine reports if other is part of the release line version is being released into
+func sameReleaseLine(version *semver.Version, other *semver.Version) bool {
+	switch {
+	case version.Patch() != 0:
+		return version.Major() == other.Major() && version.Minor() == other.Minor()
+	case version.Minor() != 0:
+		return version.Major() == other.Major()
+	default:
+		return true
+	}
+}
+
+// rollback removes the tags created by this run from the local repo when they couldn't be published, so the next run
+// doesn't compute its versions from tags nobody else has
+func (t *Tagbot) rollback(ctx context.Context, created []CreatedTag) {
+	log := zerolog.Ctx(ctx)
+	if len(created) == 0 {
+		return
+	}
+
+	deleted := []string{}
+	restored := []string{}
+	for _, tag := range created {
+		if tag.Previous == nil {
+			deleted = append(deleted, tag.Name)
+		} else {
+			restored = append(restored, tag.Name)
+		}
+	}
+
+	// the run may have failed because its context was cancelled, but the rollback still needs to happen
+	if err := t.repo.RollbackTags(context.WithoutCancel(ctx), created); err != nil {
+		log.Err(err).Strs("created", deleted).Strs("moved", restored).Msg("error rolling back tags, they may need fixing by hand")
+		return
+	}
+
+	log.Warn().Strs("deleted", deleted).Strs("restored", restored).Msg("rolled back tags that weren't pushed")
+}
+
+// bumpForCommit determines the bump for a single commit. Autosquash commits (fixup!/squash!/amend!) are classified by
+// the commit they target, if its present in the window
+func (t *Tagbot) bumpForCommit(ctx context.Context, commit *Commit, window []*Commit) VersionBump {
+	target := AutosquashTarget(commit.Message)
+	if target == "" {
+		return VersionBumpFromCommitMessage(ctx, commit.Message)
+	}
+
+	idx := slices.IndexFunc(window, func(c *Commit) bool {
+		header, _, _ := strings.Cut(c.Message, "\n")
+		return c != commit && header == target
+	})
+	if idx == -1 {
+		return VersionBumpFromCommitMessage(ctx, commit.Message)
+	}
+
+	zerolog.Ctx(ctx).Trace().Msgf("classifying %v by its target %v", commit.ShortHash, window[idx].ShortHash)
+	return VersionBumpFromCommitMessage(ctx, window[idx].Message)
+}
+
+// dropRevertedCommits removes any reverts from the (newest first) list of commits, along with the commits they revert,
+// provided both are present in the list. Reverts of commits outside the list are left in place
+func (t *Tagbot) dropRevertedCommits(ctx context.Context, commits []*Commit) []*Commit {
 	log := zerolog.Ctx(ctx)
 
-	relevant, err := t.commitRelevantToComponent(component, commit)
+	dropped := map[string]bool{}
+	for i, commit := range commits {
+		if dropped[commit.Hash] {
+			continue
+		}
+
+		revertedHash := RevertedCommitHash(commit.Message)
+		if revertedHash == "" {
+			continue
+		}
+
+		// since we're newest first, the reverted commit can only be further down the list
+		idx := slices.IndexFunc(commits[i+1:], func(c *Commit) bool {
+			return strings.HasPrefix(c.Hash, revertedHash)
+		})
+		if idx == -1 {
+			log.Trace().Msgf("commit %v reverts a commit outside the current window", commit.ShortHash)
+			continue
+		}
+
+		reverted := commits[i+1+idx]
+		log.Debug().Msgf("commit %v reverts %v, ignoring both", commit.ShortHash, reverted.ShortHash)
+		dropped[commit.Hash] = true
+		dropped[reverted.Hash] = true
+	}
+
+	kept := []*Commit{}
+	for _, commit := range commits {
+		if !dropped[commit.Hash] {
+			kept = append(kept, commit)
+		}
+	}
+	return kept
+}
+
+func (t *Tagbot) commitRelevantToComponent(ctx context.Context, component *config.MonoRepoComponent, commit *Commit) (bool, error) {
+	pathMatch, err := t.commitFilesMatchComponent(component, commit)
 	if err != nil {
-		return VersionBump(-1), err
+		return false, err
 	}
 
-	if !relevant {
-		log.Trace().Msgf("commit determined not relevant to %v", component.Name)
-		return VersionBumpIrrelevant, nil
+	// Commits that aren't scoped to a known component are routed purely by the files they touch
+	scopes, err := t.routingScopes(ctx, commit)
+	if err != nil {
+		return false, err
+	}
+	if len(scopes) == 0 {
+		return pathMatch, nil
 	}
 
-	return VersionBumpFromCommitMessage(ctx, commit.Message), nil
+	scopeMatch := slices.ContainsFunc(scopes, func(scope string) bool {
+		return slices.Contains(component.Scopes, scope)
+	})
+
+	if t.monorepoConfig.ScopeMode == config.ScopeModeScopeAndPath {
+		return scopeMatch && pathMatch, nil
+	}
+	return scopeMatch, nil
 }
 
-func (t *Tagbot) commitRelevantToComponent(component *config.MonoRepoComponent, commit *Commit) (bool, error) {
+func (t *Tagbot) commitFilesMatchComponent(component *config.MonoRepoComponent, commit *Commit) (bool, error) {
 	for _, file := range commit.Files {
 		for _, glob := range component.ChangeSetGlobs {
 			match, err := doublestar.Match(glob, file)
@@ -238,21 +627,115 @@ func (t *Tagbot) commitRelevantToComponent(component *config.MonoRepoComponent,
 	return false, nil
 }
 
+// routingScopes returns the known scopes a commit is routed by. A revert has no scope of its own (its header is
+// `Revert "feat(api): ..."`), so is routed the same as the commit it reverts, keeping the two in the same components
+func (t *Tagbot) routingScopes(ctx context.Context, commit *Commit) ([]string, error) {
+	message := commit.Message
+	// reverts of reverts are followed back to the original, within reason
+	for range maxRevertDepth {
+		if scopes := t.knownScopes(ScopesFromCommitMessage(message)); len(scopes) > 0 {
+			return scopes, nil
+		}
+
+		revertedHash := RevertedCommitHash(message)
+		if revertedHash == "" {
+			return nil, nil
+		}
+		reverted, ok, err := t.repo.CommitMessage(ctx, revertedHash)
+		if err != nil {
+			return nil, fmt.Errorf("error getting reverted commit: %w", err)
+		}
+		if !ok {
+			zerolog.Ctx(ctx).Debug().Msgf("commit %v reverts %v, which isn't in the repository, routing it by its files", commit.ShortHash, revertedHash)
+			return nil, nil
+		}
+		message = reverted
+	}
+	return nil, nil
+}
+
+// knownScopes filters the given scopes down to those that name a configured component
+func (t *Tagbot) knownScopes(scopes []string) []string {
+	known := []string{}
+	if t.monorepoConfig == nil {
+		return known
+	}
+
+	for _, scope := range scopes {
+		for _, component := range t.monorepoConfig.Components {
+			if slices.Contains(component.Scopes, scope) {
+				known = append(known, scope)
+				break
+			}
+		}
+	}
+	return known
+}
+
+// usesScopes indicates if any component has scopes configured, and thus if commit scopes should be validated
+func (t *Tagbot) usesScopes() bool {
+	if t.monorepoConfig == nil {
+		return false
+	}
+
+	for _, component := range t.monorepoConfig.Components {
+		if len(component.Scopes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *Tagbot) CommitMessage(ctx context.Context, content string) error {
 	disabled, err := t.repo.IsTagbotDisabled()
 	if err != nil {
 		return fmt.Errorf("error checking if tagbot is disabled: %w", err)
 	}
 
+	log := zerolog.Ctx(ctx)
 	if disabled {
-		log := zerolog.Ctx(ctx)
 		log.Debug().Msg("skipping validation, tagbot disabled")
 		return nil
 	}
 
+	cleanupConfig, err := t.repo.GetCleanupConfig()
+	if err != nil {
+		return fmt.Errorf("error getting commit cleanup config: %w", err)
+	}
+	content = CleanupCommitMessage(content, cleanupConfig)
+
+	// Messages git generates itself are allowed through untouched, if configured to
+	if kind, ok := GeneratedMessageKindFromMessage(content); ok && slices.Contains(t.allowedGeneratedMessages, kind) {
+		log.Debug().Msgf("skipping validation of generated %v message", kind)
+		return nil
+	}
+
 	if _, err := EnsureValidCommitMessage(ctx, content); err != nil {
 		return err
 	}
 
+	if t.usesScopes() {
+		scopes := ScopesFromCommitMessage(content)
+		known := t.knownScopes(scopes)
+		for _, scope := range scopes {
+			if !slices.Contains(known, scope) {
+				return fmt.Errorf("%w: '%v' does not name a component", ErrUnknownScopeError, scope)
+			}
+		}
+	}
+
+	errorCount := 0
+	for _, violation := range LintCommitMessage(t.lintConfig, content) {
+		if violation.Severity == config.LintSeverityWarning {
+			log.Warn().Msg(violation.String())
+			continue
+		}
+		log.Error().Msg(violation.String())
+		errorCount++
+	}
+	if errorCount > 0 {
+		return fmt.Errorf("%w: %v violation(s)", ErrLintError, errorCount)
+	}
+
 	return nil
 }
@@ -2,9 +2,12 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"testing"
 
+	gogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/nicjohnson145/hlp"
 	"github.com/nicjohnson145/tagbot/internal/config"
 	"github.com/rs/zerolog"
@@ -128,6 +131,68 @@ func TestRun(t *testing.T) {
 		})
 	})
 
+	t.Run("monorepo move between components", func(t *testing.T) {
+		repo := newMemoryRepo(
+			t,
+			testCommit{
+				Message: "feat: add x",
+				Tags:    []string{"libs/v0.1.0", "api/v0.1.0"},
+				Files: []string{
+					"libs/old/x.go",
+					"services/api/main.go",
+				},
+			},
+		)
+		w, err := repo.repo.Worktree()
+		require.NoError(t, err)
+		require.NoError(t, repo.fs.MkdirAll("services/api", 0755))
+		require.NoError(t, repo.fs.Rename("libs/old/x.go", "services/api/x.go"))
+		_, err = w.Add("services/api/x.go")
+		require.NoError(t, err)
+		_, err = w.Remove("libs/old/x.go")
+		require.NoError(t, err)
+		_, err = w.Commit("fix: move x into the api", &gogit.CommitOptions{
+			Author: &object.Signature{
+				Name:  "tagbot",
+				Email: "tagbot@example.com",
+				When:  nextCommitTime(),
+			},
+		})
+		require.NoError(t, err)
+
+		bot := NewTagbot(TagbotConfig{
+			MonorepoConfig: &config.MonoRepoConfig{
+				Components: map[string]config.MonoRepoComponent{
+					"libs": {
+						Name:           "libs",
+						ChangeSetGlobs: []string{"libs/**"},
+						MaintainLatest: hlp.Ptr(false),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(false),
+					},
+					"api": {
+						Name:           "api",
+						ChangeSetGlobs: []string{"services/api/**"},
+						MaintainLatest: hlp.Ptr(false),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(false),
+					},
+				},
+			},
+			Repo: repo,
+		})
+
+		require.NoError(t, bot.Run(newCtxWithLog(t)))
+		mustHaveTags(t, repo, []string{
+			"libs/v0.1.0",
+			"libs/v0.1.1",
+			"api/v0.1.0",
+			"api/v0.1.1",
+		})
+	})
+
 	t.Run("monorepo always patch", func(t *testing.T) {
 		repo := newMemoryRepo(
 			t,
@@ -357,74 +422,1254 @@ func TestRun(t *testing.T) {
 		mustHaveTags(t, repo, []string{"foo/v0.1.0"})
 		require.False(t, repo.pushCalled)
 	})
-}
 
-func TestCommitRelevantToComponent(t *testing.T) {
-	t.Parallel()
+	t.Run("revert cancels reverted commit", func(t *testing.T) {
+		repo := newMemoryRepo(
+			t,
+			testCommit{
+				Message: "fix: fix a thing",
+				Tags:    []string{"v0.1.0"},
+				Files: []string{
+					"foo",
+				},
+			},
+		)
+		hashes := repo.MakeCommits(t, testCommit{
+			Message: "feat: do a thing",
+			Files: []string{
+				"foo",
+			},
+		})
+		repo.MakeCommits(t, testCommit{
+			Message: "Revert \"feat: do a thing\"\n\nThis reverts commit " + hashes[0].String() + ".\n",
+			Files: []string{
+				"foo",
+			},
+		})
 
-	testData := []struct {
-		name     string
-		pattern  string
-		file     string
-		expected bool
-	}{
-		{
-			name:     "anything",
-			pattern:  "**/*",
-			file:     "internal/bot/version.go",
-			expected: true,
-		},
-		{
-			name:     "top level file",
-			pattern:  "**/*",
-			file:     "version.go",
-			expected: true,
-		},
-		{
-			name:     "subdir - no match",
-			pattern:  "foo/*",
-			file:     "internal/bot/version.go",
-			expected: false,
-		},
-		{
-			name:     "subdir multiglob - no match",
-			pattern:  "foo/**/*",
-			file:     "internal/foo/version.go",
-			expected: false,
-		},
-		{
-			name:     "subdir - yes match",
-			pattern:  "internal/**/*",
-			file:     "internal/bot/subdir/version.go",
-			expected: true,
-		},
-		{
-			name:     "multiglob top level",
-			pattern:  "internal/**/*",
-			file:     "internal/version.go",
-			expected: true,
-		},
-	}
-	for _, tc := range testData {
-		t.Run(tc.name, func(t *testing.T) {
-			t.Parallel()
+		bot := NewTagbot(TagbotConfig{
+			MonorepoConfig: &config.MonoRepoConfig{
+				Components: map[string]config.MonoRepoComponent{
+					"core": {
+						Name:           "core",
+						ChangeSetGlobs: []string{"**/*"},
+						Prefix:         hlp.Ptr(""),
+						MaintainLatest: hlp.Ptr(false),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(false),
+					},
+				},
+			},
+			Repo: repo,
+		})
 
-			bot := &Tagbot{}
+		require.NoError(t, bot.Run(newCtxWithLog(t)))
+		mustHaveTags(t, repo, []string{"v0.1.0"})
+		require.False(t, repo.pushCalled)
+	})
 
-			got, err := bot.commitRelevantToComponent(
-				&config.MonoRepoComponent{
-					ChangeSetGlobs: []string{
-						tc.pattern,
+	t.Run("revert of released commit patches", func(t *testing.T) {
+		repo := newMemoryRepo(t)
+		hashes := repo.MakeCommits(t, testCommit{
+			Message: "feat: do a thing",
+			Tags:    []string{"v0.1.0"},
+			Files: []string{
+				"foo",
+			},
+		})
+		repo.MakeCommits(
+			t,
+			testCommit{
+				Message: "fix: fix a thing",
+				Files: []string{
+					"bar",
+				},
+			},
+			testCommit{
+				Message: "revert: do a thing\n\nThis reverts commit " + hashes[0].String()[:10] + ".\n",
+				Files: []string{
+					"foo",
+				},
+			},
+		)
+
+		bot := NewTagbot(TagbotConfig{
+			MonorepoConfig: &config.MonoRepoConfig{
+				Components: map[string]config.MonoRepoComponent{
+					"core": {
+						Name:           "core",
+						ChangeSetGlobs: []string{"**/*"},
+						Prefix:         hlp.Ptr(""),
+						MaintainLatest: hlp.Ptr(false),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(false),
 					},
 				},
-				&Commit{
-					Files: []string{
-						tc.file,
+			},
+			Repo: repo,
+		})
+
+		require.NoError(t, bot.Run(newCtxWithLog(t)))
+		mustHaveTags(t, repo, []string{"v0.1.0", "v0.1.1"})
+	})
+
+	t.Run("monorepo scope routing", func(t *testing.T) {
+		repo := newMemoryRepo(
+			t,
+			testCommit{
+				Message: "feat: initial",
+				Tags:    []string{"api/v0.1.0", "web/v0.1.0"},
+				Files: []string{
+					"shared/lib.go",
+				},
+			},
+			testCommit{
+				Message: "feat(api): shared change for the api",
+				Files: []string{
+					"shared/lib.go",
+				},
+			},
+			testCommit{
+				Message: "fix: shared change for everyone",
+				Files: []string{
+					"shared/lib.go",
+				},
+			},
+		)
+
+		bot := NewTagbot(TagbotConfig{
+			MonorepoConfig: &config.MonoRepoConfig{
+				Components: map[string]config.MonoRepoComponent{
+					"api": {
+						Name:           "api",
+						ChangeSetGlobs: []string{"api/**/*", "shared/**/*"},
+						Scopes:         []string{"api", "api-gateway"},
+						MaintainLatest: hlp.Ptr(false),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(false),
+					},
+					"web": {
+						Name:           "web",
+						ChangeSetGlobs: []string{"web/**/*", "shared/**/*"},
+						Scopes:         []string{"web"},
+						MaintainLatest: hlp.Ptr(false),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(false),
 					},
 				},
-			)
-			require.NoError(t, err)
-			require.Equal(t, tc.expected, got)
+				ScopeMode: config.ScopeModeScope,
+			},
+			Repo: repo,
 		})
-	}
+
+		require.NoError(t, bot.Run(newCtxWithLog(t)))
+		mustHaveTags(t, repo, []string{"api/v0.1.0", "api/v0.2.0", "web/v0.1.0", "web/v0.1.1"})
+	})
+
+	t.Run("monorepo scope and path routing", func(t *testing.T) {
+		repo := newMemoryRepo(
+			t,
+			testCommit{
+				Message: "feat: initial",
+				Tags:    []string{"api/v0.1.0", "web/v0.1.0"},
+				Files: []string{
+					"shared/lib.go",
+				},
+			},
+			testCommit{
+				Message: "feat(api): scoped to api, but only touches web",
+				Files: []string{
+					"web/main.go",
+				},
+			},
+		)
+
+		bot := NewTagbot(TagbotConfig{
+			MonorepoConfig: &config.MonoRepoConfig{
+				Components: map[string]config.MonoRepoComponent{
+					"api": {
+						Name:           "api",
+						ChangeSetGlobs: []string{"api/**/*"},
+						Scopes:         []string{"api"},
+						MaintainLatest: hlp.Ptr(false),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(false),
+					},
+					"web": {
+						Name:           "web",
+						ChangeSetGlobs: []string{"web/**/*"},
+						Scopes:         []string{"web"},
+						MaintainLatest: hlp.Ptr(false),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(false),
+					},
+				},
+				ScopeMode: config.ScopeModeScopeAndPath,
+			},
+			Repo: repo,
+		})
+
+		require.NoError(t, bot.Run(newCtxWithLog(t)))
+		mustHaveTags(t, repo, []string{"api/v0.1.0", "web/v0.1.0"})
+	})
+
+	t.Run("monorepo scoped revert routing", func(t *testing.T) {
+		repo := newMemoryRepo(
+			t,
+			testCommit{
+				Message: "feat: initial",
+				Tags:    []string{"api/v0.1.0", "web/v0.1.0"},
+				Files: []string{
+					"shared/lib.go",
+				},
+			},
+		)
+		hashes := repo.MakeCommits(t, testCommit{
+			Message: "feat(api): shared change for the api",
+			Files: []string{
+				"shared/lib.go",
+			},
+		})
+		// the revert has no scope of its own, but still only concerns the api
+		repo.MakeCommits(t, testCommit{
+			Message: "Revert \"feat(api): shared change for the api\"\n\nThis reverts commit " + hashes[0].String() + ".\n",
+			Files: []string{
+				"shared/lib.go",
+			},
+		})
+
+		bot := NewTagbot(TagbotConfig{
+			MonorepoConfig: &config.MonoRepoConfig{
+				Components: map[string]config.MonoRepoComponent{
+					"api": {
+						Name:           "api",
+						ChangeSetGlobs: []string{"api/**/*", "shared/**/*"},
+						Scopes:         []string{"api"},
+						MaintainLatest: hlp.Ptr(false),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(false),
+					},
+					"web": {
+						Name:           "web",
+						ChangeSetGlobs: []string{"web/**/*", "shared/**/*"},
+						Scopes:         []string{"web"},
+						MaintainLatest: hlp.Ptr(false),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(false),
+					},
+				},
+				ScopeMode: config.ScopeModeScope,
+			},
+			Repo: repo,
+		})
+
+		require.NoError(t, bot.Run(newCtxWithLog(t)))
+		mustHaveTags(t, repo, []string{"api/v0.1.0", "web/v0.1.0"})
+	})
+
+	t.Run("fixup classified by target", func(t *testing.T) {
+		repo := newMemoryRepo(
+			t,
+			testCommit{
+				Message: "fix: fix a thing",
+				Tags:    []string{"v0.1.0"},
+				Files: []string{
+					"foo",
+				},
+			},
+			testCommit{
+				Message: "feat: do a thing\n\nBREAKING CHANGE: it breaks\n",
+				Files: []string{
+					"foo",
+				},
+			},
+			testCommit{
+				Message: "fixup! feat: do a thing",
+				Files: []string{
+					"bar",
+				},
+			},
+		)
+
+		bot := NewTagbot(TagbotConfig{
+			MonorepoConfig: &config.MonoRepoConfig{
+				Components: map[string]config.MonoRepoComponent{
+					"bar": {
+						Name:           "bar",
+						ChangeSetGlobs: []string{"bar"},
+						Prefix:         hlp.Ptr(""),
+						MaintainLatest: hlp.Ptr(false),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(false),
+					},
+				},
+			},
+			Repo: repo,
+		})
+
+		// the target isn't relevant to the component, so the fixup is classified by its header alone
+		require.NoError(t, bot.Run(newCtxWithLog(t)))
+		mustHaveTags(t, repo, []string{"v0.1.0", "v0.2.0"})
+	})
+
+	t.Run("unreachable higher tag ignored", func(t *testing.T) {
+		repo := newMemoryRepo(
+			t,
+			testCommit{
+				Message: "feat: initial",
+				Tags:    []string{"v0.1.0"},
+				Files: []string{
+					"foo",
+				},
+			},
+		)
+		repo.Checkout(t, "release", true)
+		repo.MakeCommits(t, testCommit{
+			Message: "feat: unmerged release",
+			Tags:    []string{"v0.5.0"},
+			Files: []string{
+				"bar",
+			},
+		})
+		repo.Checkout(t, "master", false)
+		repo.MakeCommits(t, testCommit{
+			Message: "fix: mainline fix",
+			Files: []string{
+				"foo",
+			},
+		})
+
+		bot := NewTagbot(TagbotConfig{
+			MonorepoConfig: &config.MonoRepoConfig{
+				Components: map[string]config.MonoRepoComponent{
+					"core": {
+						Name:           "core",
+						ChangeSetGlobs: []string{"**/*"},
+						Prefix:         hlp.Ptr(""),
+						MaintainLatest: hlp.Ptr(false),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(false),
+					},
+				},
+			},
+			Repo: repo,
+		})
+
+		require.NoError(t, bot.Run(newCtxWithLog(t)))
+		mustHaveTags(t, repo, []string{"v0.1.0", "v0.5.0", "v0.1.1"})
+	})
+
+	t.Run("version conflict", func(t *testing.T) {
+		// the reachable base is v0.1.0, but v0.2.0 was released on another branch
+		newDivergedRepo := func(t *testing.T, releasedTag string) *unitTestRepo {
+			repo := newMemoryRepo(
+				t,
+				testCommit{
+					Message: "feat: initial",
+					Tags:    []string{"v0.1.0"},
+					Files: []string{
+						"foo",
+					},
+				},
+			)
+			repo.Checkout(t, "release", true)
+			repo.MakeCommits(t, testCommit{
+				Message: "feat: unmerged release",
+				Tags:    []string{releasedTag},
+				Files: []string{
+					"bar",
+				},
+			})
+			repo.Checkout(t, "master", false)
+			repo.MakeCommits(t, testCommit{
+				Message: "feat: mainline feature",
+				Files: []string{
+					"foo",
+				},
+			})
+			return repo
+		}
+		newBot := func(repo *unitTestRepo, mode config.VersionConflictKind, dryRun bool) *Tagbot {
+			return NewTagbot(TagbotConfig{
+				MonorepoConfig: &config.MonoRepoConfig{
+					Components: map[string]config.MonoRepoComponent{
+						"core": {
+							Name:           "core",
+							ChangeSetGlobs: []string{"**/*"},
+							Prefix:         hlp.Ptr(""),
+							MaintainLatest: hlp.Ptr(true),
+							LatestName:     hlp.Ptr("latest"),
+							NoV:            hlp.Ptr(false),
+							AlwaysPatch:    hlp.Ptr(false),
+						},
+					},
+				},
+				Repo:                repo,
+				DryRun:              dryRun,
+				VersionConflictMode: mode,
+			})
+		}
+
+		t.Run("already exists", func(t *testing.T) {
+			repo := newDivergedRepo(t, "v0.2.0")
+			err := newBot(repo, config.VersionConflictKindFail, false).Run(newCtxWithLog(t))
+			require.ErrorIs(t, err, ErrVersionConflict)
+			require.ErrorContains(t, err, "v0.2.0 already exists")
+			mustHaveTags(t, repo, []string{"v0.1.0", "v0.2.0"})
+			require.False(t, repo.pushCalled)
+		})
+
+		t.Run("sorts below", func(t *testing.T) {
+			repo := newDivergedRepo(t, "v0.5.0")
+			err := newBot(repo, config.VersionConflictKindFail, false).Run(newCtxWithLog(t))
+			require.ErrorIs(t, err, ErrVersionConflict)
+			require.ErrorContains(t, err, "v0.2.0 sorts below the existing v0.5.0")
+			mustHaveTags(t, repo, []string{"v0.1.0", "v0.5.0"})
+		})
+
+		t.Run("backport", func(t *testing.T) {
+			// a fix on a maintenance branch cut from v1.2.0, while v2.0.0 and v1.3.0 are released from master
+			repo, g := newMemoryGitRepo(
+				t,
+				testCommit{
+					Message: "feat: initial",
+					Tags:    []string{"v1.2.0"},
+					Files: []string{
+						"foo",
+					},
+				},
+			)
+			repo.Checkout(t, "release/1.2", true)
+			repo.Checkout(t, "master", false)
+			repo.MakeCommits(
+				t,
+				testCommit{Message: "feat: new thing", Tags: []string{"v1.3.0"}, Files: []string{"foo"}},
+				testCommit{Message: "feat!: breaking thing", Tags: []string{"v2.0.0"}, Files: []string{"foo"}},
+			)
+			repo.Checkout(t, "release/1.2", false)
+			repo.MakeCommits(t, testCommit{Message: "fix: backported fix", Files: []string{"foo"}})
+
+			require.NoError(t, newBot(repo, config.VersionConflictKindFail, false).Run(newCtxWithLog(t)))
+			mustHaveTags(t, repo, []string{"v1.2.0", "v1.3.0", "v2.0.0", "v1.2.1", "latest"})
+
+			// but releasing below the newest patch of the same line isn't
+			require.NoError(t, repo.repo.DeleteTag("v1.2.1"))
+			released, err := repo.repo.Tag("v2.0.0")
+			require.NoError(t, err)
+			_, err = repo.repo.CreateTag("v1.2.4", released.Hash(), nil)
+			require.NoError(t, err)
+			g.tagsByPrefix = nil
+
+			err = newBot(repo, config.VersionConflictKindFail, false).Run(newCtxWithLog(t))
+			require.ErrorIs(t, err, ErrVersionConflict)
+			require.ErrorContains(t, err, "v1.2.1 sorts below the existing v1.2.4")
+		})
+
+		t.Run("fails by default", func(t *testing.T) {
+			repo := newDivergedRepo(t, "v0.5.0")
+			require.ErrorIs(t, newBot(repo, "", false).Run(newCtxWithLog(t)), ErrVersionConflict)
+		})
+
+		t.Run("dry run", func(t *testing.T) {
+			repo := newDivergedRepo(t, "v0.5.0")
+			require.NoError(t, newBot(repo, config.VersionConflictKindFail, true).Run(newCtxWithLog(t)))
+			mustHaveTags(t, repo, []string{"v0.1.0", "v0.5.0"})
+		})
+
+		t.Run("skip", func(t *testing.T) {
+			repo := newDivergedRepo(t, "v0.5.0")
+			require.NoError(t, newBot(repo, config.VersionConflictKindSkip, false).Run(newCtxWithLog(t)))
+			mustHaveTags(t, repo, []string{"v0.1.0", "v0.5.0"})
+			require.False(t, repo.pushCalled)
+		})
+
+		t.Run("allow", func(t *testing.T) {
+			repo := newDivergedRepo(t, "v0.5.0")
+			require.NoError(t, newBot(repo, config.VersionConflictKindAllow, false).Run(newCtxWithLog(t)))
+			mustHaveTags(t, repo, []string{"v0.1.0", "v0.5.0", "v0.2.0", "latest"})
+		})
+
+		t.Run("allow never releases an existing version", func(t *testing.T) {
+			repo := newDivergedRepo(t, "v0.2.0")
+			existing, err := repo.repo.Tag("v0.2.0")
+			require.NoError(t, err)
+
+			err = newBot(repo, config.VersionConflictKindAllow, false).Run(newCtxWithLog(t))
+			require.ErrorIs(t, err, ErrVersionConflict)
+			require.ErrorContains(t, err, "v0.2.0 already exists")
+			mustHaveTags(t, repo, []string{"v0.1.0", "v0.2.0"})
+			require.False(t, repo.pushCalled)
+
+			after, err := repo.repo.Tag("v0.2.0")
+			require.NoError(t, err)
+			require.Equal(t, existing.Hash(), after.Hash())
+		})
+
+		t.Run("rolls back earlier components", func(t *testing.T) {
+			repo := newDivergedRepo(t, "foo/v0.0.1")
+			bot := newBot(repo, config.VersionConflictKindFail, false)
+			bot.monorepoConfig.Components["bar"] = config.MonoRepoComponent{
+				Name:           "bar",
+				ChangeSetGlobs: []string{"**/*"},
+				MaintainLatest: hlp.Ptr(false),
+				LatestName:     hlp.Ptr("latest"),
+				NoV:            hlp.Ptr(false),
+				AlwaysPatch:    hlp.Ptr(false),
+			}
+			bot.monorepoConfig.Components["foo"] = config.MonoRepoComponent{
+				Name:           "foo",
+				ChangeSetGlobs: []string{"**/*"},
+				MaintainLatest: hlp.Ptr(false),
+				LatestName:     hlp.Ptr("latest"),
+				NoV:            hlp.Ptr(false),
+				AlwaysPatch:    hlp.Ptr(false),
+			}
+
+			require.ErrorIs(t, bot.Run(newCtxWithLog(t)), ErrVersionConflict)
+			mustHaveTags(t, repo, []string{"v0.1.0", "foo/v0.0.1"})
+		})
+	})
+
+	t.Run("tags configured ref instead of head", func(t *testing.T) {
+		repo, g := newMemoryGitRepo(
+			t,
+			testCommit{
+				Message: "feat: initial",
+				Tags:    []string{"v0.1.0"},
+				Files: []string{
+					"foo",
+				},
+			},
+		)
+		repo.Checkout(t, "release", true)
+		hashes := repo.MakeCommits(t, testCommit{
+			Message: "fix: release fix",
+			Files: []string{
+				"foo",
+			},
+		})
+		repo.Checkout(t, "master", false)
+		repo.MakeCommits(t, testCommit{
+			Message: "feat: mainline feature",
+			Files: []string{
+				"foo",
+			},
+		})
+		g.ref = "release"
+
+		bot := NewTagbot(TagbotConfig{
+			MonorepoConfig: &config.MonoRepoConfig{
+				Components: map[string]config.MonoRepoComponent{
+					"core": {
+						Name:           "core",
+						ChangeSetGlobs: []string{"**/*"},
+						Prefix:         hlp.Ptr(""),
+						MaintainLatest: hlp.Ptr(false),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(false),
+					},
+				},
+			},
+			Repo: repo,
+		})
+
+		require.NoError(t, bot.Run(newCtxWithLog(t)))
+		mustHaveTags(t, repo, []string{"v0.1.0", "v0.1.1"})
+
+		tag, err := repo.repo.Tag("v0.1.1")
+		require.NoError(t, err)
+		tagObj, err := repo.repo.TagObject(tag.Hash())
+		require.NoError(t, err)
+		require.Equal(t, hashes[0], tagObj.Target)
+	})
+
+	t.Run("already released at head", func(t *testing.T) {
+		// a release was manually tagged lower than one already in its history, which would otherwise be patched
+		commits := []testCommit{
+			{
+				Message: "feat: initial",
+				Tags:    []string{"v0.2.0", "foo/v0.1.0"},
+				Files: []string{
+					"foo/a",
+				},
+			},
+			{
+				Message: "fix: a thing",
+				Tags:    []string{"v0.1.1"},
+				Files: []string{
+					"foo/b",
+				},
+			},
+		}
+		newComponents := func() *config.MonoRepoConfig {
+			return &config.MonoRepoConfig{
+				Components: map[string]config.MonoRepoComponent{
+					"core": {
+						Name:           "core",
+						ChangeSetGlobs: []string{"**/*"},
+						Prefix:         hlp.Ptr(""),
+						MaintainLatest: hlp.Ptr(false),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(true),
+					},
+					"foo": {
+						Name:           "foo",
+						ChangeSetGlobs: []string{"foo/*"},
+						MaintainLatest: hlp.Ptr(false),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(true),
+					},
+				},
+			}
+		}
+
+		t.Run("skipped", func(t *testing.T) {
+			repo := newMemoryRepo(t, commits...)
+			bot := NewTagbot(TagbotConfig{
+				MonorepoConfig: newComponents(),
+				Repo:           repo,
+			})
+
+			require.NoError(t, bot.Run(newCtxWithLog(t)))
+			mustHaveTags(t, repo, []string{"v0.2.0", "v0.1.1", "foo/v0.1.0", "foo/v0.1.1"})
+		})
+
+		t.Run("allowed", func(t *testing.T) {
+			repo := newMemoryRepo(t, commits...)
+			bot := NewTagbot(TagbotConfig{
+				MonorepoConfig:             newComponents(),
+				Repo:                       repo,
+				AllowMultipleTagsPerCommit: true,
+			})
+
+			require.NoError(t, bot.Run(newCtxWithLog(t)))
+			mustHaveTags(t, repo, []string{"v0.2.0", "v0.1.1", "v0.2.1", "foo/v0.1.0", "foo/v0.1.1"})
+		})
+
+		t.Run("rerun", func(t *testing.T) {
+			repo := newMemoryRepo(t, commits...)
+			bot := NewTagbot(TagbotConfig{
+				MonorepoConfig: newComponents(),
+				Repo:           repo,
+			})
+
+			require.NoError(t, bot.Run(newCtxWithLog(t)))
+			repo.pushCalled = false
+			require.NoError(t, bot.Run(newCtxWithLog(t)))
+			mustHaveTags(t, repo, []string{"v0.2.0", "v0.1.1", "foo/v0.1.0", "foo/v0.1.1"})
+			require.False(t, repo.pushCalled)
+		})
+	})
+
+	t.Run("disallowed branch", func(t *testing.T) {
+		newBot := func(repo *unitTestRepo, dryRun bool) *Tagbot {
+			return NewTagbot(TagbotConfig{
+				MonorepoConfig: &config.MonoRepoConfig{
+					Components: map[string]config.MonoRepoComponent{
+						"core": {
+							Name:           "core",
+							ChangeSetGlobs: []string{"**/*"},
+							Prefix:         hlp.Ptr(""),
+							MaintainLatest: hlp.Ptr(false),
+							LatestName:     hlp.Ptr("latest"),
+							NoV:            hlp.Ptr(false),
+							AlwaysPatch:    hlp.Ptr(false),
+						},
+					},
+				},
+				Repo:            repo,
+				DryRun:          dryRun,
+				AllowedBranches: []string{"main", "release/*"},
+			})
+		}
+
+		repo := newMemoryRepo(
+			t,
+			testCommit{
+				Message: "feat: initial",
+				Files: []string{
+					"foo",
+				},
+			},
+		)
+
+		require.NoError(t, newBot(repo, true).Run(newCtxWithLog(t)))
+		require.ErrorIs(t, newBot(repo, false).Run(newCtxWithLog(t)), ErrBranchNotAllowed)
+		mustHaveTags(t, repo, []string{})
+		require.False(t, repo.pushCalled)
+
+		repo.Checkout(t, "release/1.x", true)
+		require.NoError(t, newBot(repo, false).Run(newCtxWithLog(t)))
+		mustHaveTags(t, repo, []string{"v0.0.1"})
+	})
+
+	t.Run("manual release", func(t *testing.T) {
+		newBot := func(repo *unitTestRepo) *Tagbot {
+			return NewTagbot(TagbotConfig{
+				MonorepoConfig: &config.MonoRepoConfig{
+					Components: map[string]config.MonoRepoComponent{
+						"api": {
+							Name:              "api",
+							ChangeSetGlobs:    []string{"api/*"},
+							MaintainLatest:    hlp.Ptr(false),
+							LatestName:        hlp.Ptr("latest"),
+							NoV:               hlp.Ptr(false),
+							AlwaysPatch:       hlp.Ptr(false),
+							ReleaseMode:       hlp.Ptr(config.ReleaseKindManual),
+							ReleaseMarkerFile: hlp.Ptr("api/RELEASE"),
+						},
+						"web": {
+							Name:           "web",
+							ChangeSetGlobs: []string{"web/*"},
+							MaintainLatest: hlp.Ptr(false),
+							LatestName:     hlp.Ptr("latest"),
+							NoV:            hlp.Ptr(false),
+							AlwaysPatch:    hlp.Ptr(false),
+						},
+					},
+				},
+				Repo: repo,
+			})
+		}
+		initial := testCommit{
+			Message: "feat: initial\n\nRelease: true",
+			Tags:    []string{"api/v0.1.0", "web/v0.1.0"},
+			Files: []string{
+				"api/main.go",
+				"web/main.go",
+			},
+		}
+
+		t.Run("pending", func(t *testing.T) {
+			// the approval of an already released commit doesn't carry over
+			repo := newMemoryRepo(
+				t,
+				initial,
+				testCommit{Message: "feat: api thing", Files: []string{"api/main.go"}},
+				testCommit{Message: "feat: web thing", Files: []string{"web/main.go"}},
+			)
+
+			require.NoError(t, newBot(repo).Run(newCtxWithLog(t)))
+			mustHaveTags(t, repo, []string{"api/v0.1.0", "web/v0.1.0", "web/v0.2.0"})
+		})
+
+		t.Run("trailer", func(t *testing.T) {
+			repo := newMemoryRepo(
+				t,
+				initial,
+				testCommit{Message: "feat: api thing", Files: []string{"api/main.go"}},
+				testCommit{Message: "fix: api fix\n\nSome details\n\nrelease: TRUE", Files: []string{"api/main.go"}},
+			)
+
+			require.NoError(t, newBot(repo).Run(newCtxWithLog(t)))
+			mustHaveTags(t, repo, []string{"api/v0.1.0", "web/v0.1.0", "api/v0.2.0"})
+		})
+
+		t.Run("trailer on irrelevant commit", func(t *testing.T) {
+			repo := newMemoryRepo(
+				t,
+				initial,
+				testCommit{Message: "feat: api thing", Files: []string{"api/main.go"}},
+				testCommit{Message: "chore: release\n\nRelease: true", Files: []string{"docs/release.md"}},
+			)
+
+			require.NoError(t, newBot(repo).Run(newCtxWithLog(t)))
+			mustHaveTags(t, repo, []string{"api/v0.1.0", "web/v0.1.0", "api/v0.2.0"})
+		})
+
+		t.Run("not a trailer", func(t *testing.T) {
+			repo := newMemoryRepo(
+				t,
+				initial,
+				testCommit{Message: "feat: api thing\n\nRelease: true\n\nis what this will need", Files: []string{"api/main.go"}},
+			)
+
+			require.NoError(t, newBot(repo).Run(newCtxWithLog(t)))
+			mustHaveTags(t, repo, []string{"api/v0.1.0", "web/v0.1.0"})
+		})
+
+		t.Run("marker file", func(t *testing.T) {
+			repo := newMemoryRepo(
+				t,
+				initial,
+				testCommit{Message: "feat: api thing", Files: []string{"api/main.go"}},
+				testCommit{Message: "chore: release api", Files: []string{"api/RELEASE"}},
+			)
+
+			require.NoError(t, newBot(repo).Run(newCtxWithLog(t)))
+			mustHaveTags(t, repo, []string{"api/v0.1.0", "web/v0.1.0", "api/v0.2.0"})
+		})
+
+		t.Run("first release approved below head", func(t *testing.T) {
+			repo := newMemoryRepo(
+				t,
+				testCommit{Message: "feat: api thing", Files: []string{"api/main.go"}},
+				testCommit{Message: "chore: release\n\nRelease: true", Files: []string{"docs/release.md"}},
+				testCommit{Message: "feat: web thing", Files: []string{"web/main.go"}},
+			)
+
+			require.NoError(t, newBot(repo).Run(newCtxWithLog(t)))
+			mustHaveTags(t, repo, []string{"api/v0.0.1", "web/v0.0.1"})
+		})
+
+		t.Run("first release pending", func(t *testing.T) {
+			repo := newMemoryRepo(
+				t,
+				testCommit{Message: "feat: api thing", Files: []string{"api/main.go"}},
+				testCommit{Message: "feat: web thing", Files: []string{"web/main.go"}},
+			)
+
+			require.NoError(t, newBot(repo).Run(newCtxWithLog(t)))
+			mustHaveTags(t, repo, []string{"web/v0.0.1"})
+		})
+	})
+
+	t.Run("push failure rolls back tags", func(t *testing.T) {
+		repo := newMemoryRepo(
+			t,
+			testCommit{
+				Message: "feat: initial",
+				Tags:    []string{"v0.1.0", "latest"},
+				Files: []string{
+					"foo",
+				},
+			},
+			testCommit{
+				Message: "fix: a thing",
+				Files: []string{
+					"foo",
+				},
+			},
+		)
+		latest, err := repo.repo.Reference(plumbing.NewTagReferenceName("latest"), false)
+		require.NoError(t, err)
+
+		repo.pushErr = errors.New("remote rejected")
+		bot := NewTagbot(TagbotConfig{
+			MonorepoConfig: &config.MonoRepoConfig{
+				Components: map[string]config.MonoRepoComponent{
+					"core": {
+						Name:           "core",
+						ChangeSetGlobs: []string{"**/*"},
+						Prefix:         hlp.Ptr(""),
+						MaintainLatest: hlp.Ptr(true),
+						LatestName:     hlp.Ptr("latest"),
+						NoV:            hlp.Ptr(false),
+						AlwaysPatch:    hlp.Ptr(false),
+					},
+				},
+			},
+			Repo: repo,
+		})
+
+		require.ErrorIs(t, bot.Run(newCtxWithLog(t)), repo.pushErr)
+		require.True(t, repo.pushCalled)
+		mustHaveTags(t, repo, []string{"v0.1.0", "latest"})
+
+		// latest is back where it was
+		got, err := repo.repo.Reference(plumbing.NewTagReferenceName("latest"), false)
+		require.NoError(t, err)
+		require.Equal(t, latest.Hash(), got.Hash())
+
+		// and the next run starts over
+		repo.pushErr = nil
+		require.NoError(t, bot.Run(newCtxWithLog(t)))
+		mustHaveTags(t, repo, []string{"v0.1.0", "v0.1.1", "latest"})
+	})
+}
+
+func TestDropRevertedCommits(t *testing.T) {
+	t.Parallel()
+
+	bot := &Tagbot{}
+	commits := []*Commit{
+		{Hash: "cccccccccc", ShortHash: "cccccccccc", Message: "Revert \"Revert \"feat: thing\"\"\n\nThis reverts commit bbbbbbb.\n"},
+		{Hash: "bbbbbbbbbb", ShortHash: "bbbbbbbbbb", Message: "Revert \"feat: thing\"\n\nThis reverts commit aaaaaaaaaa.\n"},
+		{Hash: "aaaaaaaaaa", ShortHash: "aaaaaaaaaa", Message: "feat: thing"},
+		{Hash: "dddddddddd", ShortHash: "dddddddddd", Message: "revert: old thing\n\nThis reverts commit eeeeeee.\n"},
+	}
+
+	got := bot.dropRevertedCommits(context.Background(), commits)
+	require.Equal(t, []*Commit{commits[2], commits[3]}, got)
+}
+
+func TestCommitRelevantToComponent(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		name     string
+		pattern  string
+		file     string
+		expected bool
+	}{
+		{
+			name:     "anything",
+			pattern:  "**/*",
+			file:     "internal/bot/version.go",
+			expected: true,
+		},
+		{
+			name:     "top level file",
+			pattern:  "**/*",
+			file:     "version.go",
+			expected: true,
+		},
+		{
+			name:     "subdir - no match",
+			pattern:  "foo/*",
+			file:     "internal/bot/version.go",
+			expected: false,
+		},
+		{
+			name:     "subdir multiglob - no match",
+			pattern:  "foo/**/*",
+			file:     "internal/foo/version.go",
+			expected: false,
+		},
+		{
+			name:     "subdir - yes match",
+			pattern:  "internal/**/*",
+			file:     "internal/bot/subdir/version.go",
+			expected: true,
+		},
+		{
+			name:     "multiglob top level",
+			pattern:  "internal/**/*",
+			file:     "internal/version.go",
+			expected: true,
+		},
+	}
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			bot := &Tagbot{}
+
+			got, err := bot.commitRelevantToComponent(
+				context.Background(),
+				&config.MonoRepoComponent{
+					ChangeSetGlobs: []string{
+						tc.pattern,
+					},
+				},
+				&Commit{
+					Files: []string{
+						tc.file,
+					},
+				},
+			)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestCommitMessage(t *testing.T) {
+	t.Parallel()
+
+	scopedConfig := &config.MonoRepoConfig{
+		Components: map[string]config.MonoRepoComponent{
+			"api": {
+				Name:           "api",
+				ChangeSetGlobs: []string{"api/**/*"},
+				Scopes:         []string{"api"},
+			},
+		},
+	}
+
+	lintConfig := &config.LintConfig{
+		SubjectLowerCase: &config.LintRule{Severity: config.LintSeverityWarning},
+		ScopeRequired:    &config.LintRule{Severity: config.LintSeverityError},
+	}
+
+	testData := []struct {
+		name     string
+		config   *config.MonoRepoConfig
+		lint     *config.LintConfig
+		allowed  []config.GeneratedMessageKind
+		message  string
+		expected error
+	}{
+		{
+			name:     "valid",
+			message:  "feat: do a thing",
+			expected: nil,
+		},
+		{
+			name:     "invalid",
+			message:  "do a thing",
+			expected: ErrInvalidMessageError,
+		},
+		{
+			name:     "scope ignored without scoped components",
+			message:  "feat(whatever): do a thing",
+			expected: nil,
+		},
+		{
+			name:     "known scope",
+			config:   scopedConfig,
+			message:  "feat(api): do a thing",
+			expected: nil,
+		},
+		{
+			name:     "unscoped with scoped components",
+			config:   scopedConfig,
+			message:  "feat: do a thing",
+			expected: nil,
+		},
+		{
+			name:     "unknown scope",
+			config:   scopedConfig,
+			message:  "feat(api,whatever): do a thing",
+			expected: ErrUnknownScopeError,
+		},
+		{
+			name:     "leading comments",
+			message:  "# Please enter the commit message for your changes.\nfeat: do a thing\n",
+			expected: nil,
+		},
+		{
+			name:     "verbose diff",
+			message:  "fix: do a thing\n# ------------------------ >8 ------------------------\nBREAKING CHANGE in the diff\n",
+			lint:     &config.LintConfig{BreakingChangeBody: &config.LintRule{Severity: config.LintSeverityError}},
+			expected: nil,
+		},
+		{
+			name:     "generated message allowed",
+			allowed:  []config.GeneratedMessageKind{config.GeneratedMessageKindMerge, config.GeneratedMessageKindFixup},
+			lint:     lintConfig,
+			message:  "fixup! Feat: do a thing",
+			expected: nil,
+		},
+		{
+			name:     "generated message not allowed",
+			allowed:  []config.GeneratedMessageKind{config.GeneratedMessageKindMerge},
+			message:  "Revert \"feat: do a thing\"",
+			expected: ErrInvalidMessageError,
+		},
+		{
+			name:     "lint warnings only",
+			lint:     lintConfig,
+			message:  "feat(api): Do a thing",
+			expected: nil,
+		},
+		{
+			name:     "lint errors",
+			lint:     lintConfig,
+			message:  "feat: do a thing",
+			expected: ErrLintError,
+		},
+	}
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			bot := NewTagbot(TagbotConfig{
+				MonorepoConfig:           tc.config,
+				LintConfig:               tc.lint,
+				AllowedGeneratedMessages: tc.allowed,
+				Repo:                     newMemoryRepo(t),
+			})
+
+			err := bot.CommitMessage(context.Background(), tc.message)
+			if tc.expected == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.expected)
+			}
+		})
+	}
+}
+
+func TestRunMerges(t *testing.T) {
+	newComponents := func() *config.MonoRepoConfig {
+		return &config.MonoRepoConfig{
+			Components: map[string]config.MonoRepoComponent{
+				"core": {
+					Name:           "core",
+					ChangeSetGlobs: []string{"**/*"},
+					Prefix:         hlp.Ptr(""),
+					MaintainLatest: hlp.Ptr(false),
+					LatestName:     hlp.Ptr("latest"),
+					NoV:            hlp.Ptr(false),
+					AlwaysPatch:    hlp.Ptr(false),
+				},
+			},
+		}
+	}
+
+	// main:    A(v0.1.0) -- B(fix) ------- M
+	//            \                        /
+	// feature:    F1(feat) ------------- F2(docs)
+	newMergedRepo := func(t *testing.T) *unitTestRepo {
+		repo := newMemoryRepo(
+			t,
+			testCommit{
+				Message: "feat: initial",
+				Tags:    []string{"v0.1.0"},
+				Files:   []string{"base"},
+			},
+		)
+		repo.Checkout(t, "feature", true)
+		repo.MakeCommits(
+			t,
+			testCommit{
+				Message: "feat: feature work",
+				Files:   []string{"feature/one"},
+			},
+			testCommit{
+				Message: "docs: feature docs",
+				Files:   []string{"feature/two"},
+			},
+		)
+		repo.Checkout(t, "master", false)
+		repo.MakeCommits(t, testCommit{
+			Message: "fix: mainline fix",
+			Files:   []string{"main"},
+		})
+		repo.Merge(t, "feature", "Merge branch 'feature'")
+		return repo
+	}
+
+	t.Run("all commits", func(t *testing.T) {
+		repo := newMergedRepo(t)
+		bot := NewTagbot(TagbotConfig{
+			MonorepoConfig: newComponents(),
+			WalkStrategy:   config.WalkKindAll,
+			Repo:           repo,
+		})
+
+		require.NoError(t, bot.Run(newCtxWithLog(t)))
+		mustHaveTags(t, repo, []string{"v0.1.0", "v0.2.0"})
+	})
+
+	t.Run("topological", func(t *testing.T) {
+		repo := newMergedRepo(t)
+		bot := NewTagbot(TagbotConfig{
+			MonorepoConfig: newComponents(),
+			WalkStrategy:   config.WalkKindTopological,
+			Repo:           repo,
+		})
+
+		require.NoError(t, bot.Run(newCtxWithLog(t)))
+		mustHaveTags(t, repo, []string{"v0.1.0", "v0.2.0"})
+	})
+
+	t.Run("first parent by message", func(t *testing.T) {
+		repo := newMergedRepo(t)
+		bot := NewTagbot(TagbotConfig{
+			MonorepoConfig:      newComponents(),
+			WalkStrategy:        config.WalkKindFirstParent,
+			MergeClassification: config.MergeKindMessage,
+			Repo:                repo,
+		})
+
+		require.NoError(t, bot.Run(newCtxWithLog(t)))
+		mustHaveTags(t, repo, []string{"v0.1.0", "v0.1.1"})
+	})
+
+	t.Run("first parent by branch", func(t *testing.T) {
+		repo := newMergedRepo(t)
+		bot := NewTagbot(TagbotConfig{
+			MonorepoConfig:      newComponents(),
+			WalkStrategy:        config.WalkKindFirstParent,
+			MergeClassification: config.MergeKindBranch,
+			Repo:                repo,
+		})
+
+		require.NoError(t, bot.Run(newCtxWithLog(t)))
+		mustHaveTags(t, repo, []string{"v0.1.0", "v0.2.0"})
+	})
+
+	// main:    C0 -- M1(feat) ---------- M
+	//            \                      /
+	// side:       S1(fix, v0.1.0) -----
+	//
+	// with S1 committed after M1, so walking by commit time reaches the tag before the unreleased feature
+	for _, strategy := range []config.WalkKind{config.WalkKindAll, config.WalkKindTopological} {
+		t.Run("tag on side branch newer than mainline "+strategy.String(), func(t *testing.T) {
+			repo := newMemoryRepo(
+				t,
+				testCommit{
+					Message: "chore: initial",
+					Files:   []string{"base"},
+				},
+			)
+			repo.Checkout(t, "side", true)
+			repo.Checkout(t, "master", false)
+			repo.MakeCommits(t, testCommit{
+				Message: "feat: mainline feature",
+				Files:   []string{"main"},
+			})
+			repo.Checkout(t, "side", false)
+			repo.MakeCommits(t, testCommit{
+				Message: "fix: side",
+				Tags:    []string{"v0.1.0"},
+				Files:   []string{"side"},
+			})
+			repo.Checkout(t, "master", false)
+			repo.Merge(t, "side", "Merge branch 'side'")
+
+			bot := NewTagbot(TagbotConfig{
+				MonorepoConfig: newComponents(),
+				WalkStrategy:   strategy,
+				Repo:           repo,
+			})
+
+			require.NoError(t, bot.Run(newCtxWithLog(t)))
+			mustHaveTags(t, repo, []string{"v0.1.0", "v0.2.0"})
+		})
+	}
+
+	t.Run("first parent with tag on side branch", func(t *testing.T) {
+		repo := newMemoryRepo(
+			t,
+			testCommit{
+				Message: "feat: initial",
+				Files:   []string{"base"},
+			},
+		)
+		repo.Checkout(t, "feature", true)
+		repo.MakeCommits(
+			t,
+			testCommit{
+				Message: "feat: feature work",
+				Tags:    []string{"v0.2.0"},
+				Files:   []string{"feature/one"},
+			},
+			testCommit{
+				Message: "fix: feature fix",
+				Files:   []string{"feature/two"},
+			},
+		)
+		repo.Checkout(t, "master", false)
+		repo.MakeCommits(t, testCommit{
+			Message: "docs: mainline docs",
+			Files:   []string{"main"},
+		})
+		repo.Merge(t, "feature", "Merge branch 'feature'")
+
+		bot := NewTagbot(TagbotConfig{
+			MonorepoConfig:      newComponents(),
+			WalkStrategy:        config.WalkKindFirstParent,
+			MergeClassification: config.MergeKindBranch,
+			Repo:                repo,
+		})
+
+		require.NoError(t, bot.Run(newCtxWithLog(t)))
+		mustHaveTags(t, repo, []string{"v0.2.0", "v0.2.1"})
+	})
 }
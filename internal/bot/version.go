@@ -9,6 +9,7 @@ import (
 
 	"github.com/Masterminds/semver"
 	"github.com/nicjohnson145/hlp"
+	"github.com/nicjohnson145/tagbot/internal/config"
 	"github.com/rs/zerolog"
 )
 
@@ -19,6 +20,7 @@ const (
 var (
 	InitialTag = hlp.Must(semver.NewVersion("v0.0.1"))
 	ErrInvalidMessageError = errors.New("invalid commit message")
+	ErrUnknownScopeError   = errors.New("unknown commit scope")
 )
 
 //go:generate go-enum -f $GOFILE -marshal -names
@@ -49,11 +51,80 @@ var prefixMap = map[string]VersionBump{
 	"perf":     VersionBumpPatch,
 	"test":     VersionBumpNone,
 	"ci":       VersionBumpNone,
+	"revert":   VersionBumpPatch,
 }
 
-var messagesRegex = regexp.MustCompile(fmt.Sprintf(`(?i)^(?P<prefix>%v)(\(.*\))?(?P<breaking>!?): .*`, strings.Join(hlp.Keys(prefixMap), "|")))
+var messagesRegex = regexp.MustCompile(fmt.Sprintf(`(?i)^(?P<prefix>%v)(\((?P<scope>.*?)\))?(?P<breaking>!?): (?P<subject>.*)`, strings.Join(hlp.Keys(prefixMap), "|")))
+
+// generatedMessageRegexes match the headers git generates for merges, reverts, and the various autosquash commits
+var generatedMessageRegexes = []struct {
+	kind  config.GeneratedMessageKind
+	regex *regexp.Regexp
+}{
+	{kind: config.GeneratedMessageKindMerge, regex: regexp.MustCompile(`^Merge (branch|branches|remote-tracking branch|tag|tags|commit|pull request) `)},
+	{kind: config.GeneratedMessageKindRevert, regex: regexp.MustCompile(`^Revert ".*"`)},
+	{kind: config.GeneratedMessageKindFixup, regex: regexp.MustCompile(`^fixup! `)},
+	{kind: config.GeneratedMessageKindSquash, regex: regexp.MustCompile(`^squash! `)},
+	{kind: config.GeneratedMessageKindAmend, regex: regexp.MustCompile(`^amend! `)},
+}
+
+var autosquashPrefixRegex = regexp.MustCompile(`^((fixup|squash|amend)! )+`)
+
+// revertedCommitRegex matches the trailer `git revert` adds to the body of the commit
+var revertedCommitRegex = regexp.MustCompile(`(?m)^This reverts commit (?P<hash>[0-9a-fA-F]{7,40})`)
+
+// RevertedCommitHash returns the (possibly abbreviated) hash of the commit the message reverts, or an empty string if the
+// message is not a revert
+func RevertedCommitHash(message string) string {
+	parts := hlp.ExtractNamedMatches(revertedCommitRegex, revertedCommitRegex.FindStringSubmatch(message))
+	return strings.ToLower(parts["hash"])
+}
+
+// ScopesFromCommitMessage returns the scopes in the header of a conventional commit, i.e `api` and `web` for
+// `feat(api,web): do a thing`
+func ScopesFromCommitMessage(message string) []string {
+	parts := hlp.ExtractNamedMatches(messagesRegex, messagesRegex.FindStringSubmatch(message))
+
+	scopes := []string{}
+	for _, scope := range strings.Split(parts["scope"], ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// GeneratedMessageKindFromMessage detects messages generated by git itself, which dont follow the conventional commit
+// format
+func GeneratedMessageKindFromMessage(message string) (config.GeneratedMessageKind, bool) {
+	for _, generated := range generatedMessageRegexes {
+		if generated.regex.MatchString(message) {
+			return generated.kind, true
+		}
+	}
+	return config.GeneratedMessageKind(""), false
+}
+
+// AutosquashTarget returns the header of the commit a fixup!/squash!/amend! commit targets, or an empty string if the
+// message isn't an autosquash commit
+func AutosquashTarget(message string) string {
+	header, _, _ := strings.Cut(message, "\n")
+	if !autosquashPrefixRegex.MatchString(header) {
+		return ""
+	}
+	return autosquashPrefixRegex.ReplaceAllString(header, "")
+}
 
 func VersionBumpFromCommitMessage(ctx context.Context, message string) VersionBump {
+	if kind, ok := GeneratedMessageKindFromMessage(message); ok {
+		switch kind {
+		case config.GeneratedMessageKindRevert:
+			return prefixMap["revert"]
+		case config.GeneratedMessageKindFixup, config.GeneratedMessageKindSquash, config.GeneratedMessageKindAmend:
+			return VersionBumpFromCommitMessage(ctx, AutosquashTarget(message))
+		}
+	}
+
 	bump, _ := EnsureValidCommitMessage(ctx, message)
 	return bump
 }
@@ -4,8 +4,9 @@ import (
 	"context"
 	"testing"
 
-	"github.com/go-jose/go-jose/v4/testutils/require"
 	"github.com/lithammer/dedent"
+	"github.com/nicjohnson145/tagbot/internal/config"
+	"github.com/stretchr/testify/require"
 )
 
 func TestVersionBumpFromCommitMessage(t *testing.T) {
@@ -89,6 +90,38 @@ func TestVersionBumpFromCommitMessage(t *testing.T) {
 			`[1:]),
 			expected: VersionBumpMajor,
 		},
+		{
+			name: "revert type",
+			message: dedent.Dedent(`
+				revert: feat: do a thing
+
+				This reverts commit 0123456789abcdef.
+			`[1:]),
+			expected: VersionBumpPatch,
+		},
+		{
+			name: "git generated revert",
+			message: dedent.Dedent(`
+				Revert "feat: do a thing"
+
+				This reverts commit 0123456789abcdef.
+			`[1:]),
+			expected: VersionBumpPatch,
+		},
+		{
+			name: "fixup",
+			message: dedent.Dedent(`
+				fixup! feat: do a thing
+			`[1:]),
+			expected: VersionBumpMinor,
+		},
+		{
+			name: "merge",
+			message: dedent.Dedent(`
+				Merge branch 'feature' into main
+			`[1:]),
+			expected: VersionBumpNone,
+		},
 	}
 	for _, tc := range testData {
 		t.Run(tc.name, func(t *testing.T) {
@@ -99,3 +132,155 @@ func TestVersionBumpFromCommitMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestRevertedCommitHash(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		name     string
+		message  string
+		expected string
+	}{
+		{
+			name: "git generated revert",
+			message: dedent.Dedent(`
+				Revert "feat: do a thing"
+
+				This reverts commit 0123456789ABCDEF0123456789abcdef01234567.
+			`[1:]),
+			expected: "0123456789abcdef0123456789abcdef01234567",
+		},
+		{
+			name: "abbreviated hash",
+			message: dedent.Dedent(`
+				revert: do a thing
+
+				This reverts commit 0123456.
+			`[1:]),
+			expected: "0123456",
+		},
+		{
+			name: "not a revert",
+			message: dedent.Dedent(`
+				feat: do a thing
+			`[1:]),
+			expected: "",
+		},
+	}
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, RevertedCommitHash(tc.message), tc.expected)
+		})
+	}
+}
+
+func TestScopesFromCommitMessage(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		name     string
+		message  string
+		expected []string
+	}{
+		{
+			name:     "no scope",
+			message:  "feat: do a thing",
+			expected: []string{},
+		},
+		{
+			name:     "single scope",
+			message:  "feat(api): do a thing (finally)",
+			expected: []string{"api"},
+		},
+		{
+			name:     "multiple scopes",
+			message:  "fix(api, web)!: do a thing",
+			expected: []string{"api", "web"},
+		},
+		{
+			name:     "non conforming",
+			message:  "do a thing",
+			expected: []string{},
+		},
+	}
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, ScopesFromCommitMessage(tc.message), tc.expected)
+		})
+	}
+}
+
+func TestGeneratedMessageKindFromMessage(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		name     string
+		message  string
+		expected config.GeneratedMessageKind
+		ok       bool
+	}{
+		{
+			name:     "merge branch",
+			message:  "Merge branch 'feature'",
+			expected: config.GeneratedMessageKindMerge,
+			ok:       true,
+		},
+		{
+			name:     "merge pull request",
+			message:  "Merge pull request #12 from someone/feature",
+			expected: config.GeneratedMessageKindMerge,
+			ok:       true,
+		},
+		{
+			name:     "revert",
+			message:  "Revert \"feat: do a thing\"\n\nThis reverts commit 0123456.\n",
+			expected: config.GeneratedMessageKindRevert,
+			ok:       true,
+		},
+		{
+			name:     "fixup",
+			message:  "fixup! feat: do a thing",
+			expected: config.GeneratedMessageKindFixup,
+			ok:       true,
+		},
+		{
+			name:     "squash",
+			message:  "squash! feat: do a thing",
+			expected: config.GeneratedMessageKindSquash,
+			ok:       true,
+		},
+		{
+			name:     "amend",
+			message:  "amend! feat: do a thing\n\nfeat: do a thing better\n",
+			expected: config.GeneratedMessageKindAmend,
+			ok:       true,
+		},
+		{
+			name:     "conventional",
+			message:  "feat: merge branch handling",
+			expected: config.GeneratedMessageKind(""),
+			ok:       false,
+		},
+	}
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := GeneratedMessageKindFromMessage(tc.message)
+			require.Equal(t, tc.expected, got)
+			require.Equal(t, tc.ok, ok)
+		})
+	}
+}
+
+func TestAutosquashTarget(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "feat: do a thing", AutosquashTarget("fixup! feat: do a thing"))
+	require.Equal(t, "feat: do a thing", AutosquashTarget("fixup! squash! feat: do a thing\n\nmore"))
+	require.Equal(t, "", AutosquashTarget("feat: do a thing"))
+}
@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 
 	"github.com/nicjohnson145/tagbot/internal/bot"
@@ -20,22 +23,66 @@ func CommitMessage() *cobra.Command {
 			// Base logging setup
 			logger := config.NewLoggerFromEnv()
 
+			allowedGenerated, err := config.ParseGeneratedMessageKinds(viper.GetStringSlice(config.AllowedGeneratedMessages))
+			if err != nil {
+				logger.Err(err).Msg("error parsing allowed generated messages")
+				return err
+			}
+
 			// Construct our git repo
 			repo, err := bot.NewGitRepo(bot.GitRepoConfig{
-				Path: ".", // TODO: config option
-				Remote:            viper.GetString(config.RemoteName),
-				AuthMethod:        viper.GetString(config.AuthMethod),
-				AuthToken:         viper.GetString(config.AuthToken),
-				AuthKeyPath:       viper.GetString(config.AuthKeyPath),
-				AuthTokenUsername: viper.GetString(config.AuthTokenUsername),
+				Path:                  viper.GetString(config.RepoPath),
+				Remote:                viper.GetString(config.RemoteName),
+				AuthMethod:            viper.GetString(config.AuthMethod),
+				AuthToken:             viper.GetString(config.AuthToken),
+				AuthKeyPath:           viper.GetString(config.AuthKeyPath),
+				AuthKeyPassphrase:     viper.GetString(config.AuthKeyPassphrase),
+				AuthTokenUsername:     viper.GetString(config.AuthTokenUsername),
+				KnownHostsPath:        viper.GetString(config.KnownHostsPath),
+				KnownHosts:            viper.GetString(config.KnownHosts),
+				InsecureIgnoreHostKey: viper.GetBool(config.InsecureIgnoreHostKey),
 			})
 			if err != nil {
 				logger.Err(err).Msg("error creating git repo handle")
 				return err
 			}
 
+			// Load our monorepo config, if any, so commit scopes can be validated against the known components
+			var monorepoConf *config.MonoRepoConfig
+			if viper.GetBool(config.MonoRepo) {
+				path, err := repoRelativePath(repo, config.MonoRepoConfigPath, config.DefaultMonoRepoConfigPath)
+				if err != nil {
+					logger.Err(err).Msg("error resolving monorepo config path")
+					return err
+				}
+				c, err := config.ParseMonoRepoConfig(path)
+				if err != nil {
+					logger.Err(err).Msg("error parsing monorepo config")
+					return err
+				}
+				monorepoConf = c
+			}
+
+			// Load any lint rules, the default config file is allowed to not exist
+			lintPath, err := repoRelativePath(repo, config.LintConfigPath, config.DefaultLintConfigPath)
+			if err != nil {
+				logger.Err(err).Msg("error resolving lint config path")
+				return err
+			}
+			lintConf, err := config.ParseLintConfig(lintPath)
+			if err != nil {
+				if !errors.Is(err, fs.ErrNotExist) || viper.GetString(config.LintConfigPath) != config.DefaultLintConfigPath {
+					logger.Err(err).Msg("error parsing lint config")
+					return err
+				}
+				lintConf = nil
+			}
+
 			tagbot := bot.NewTagbot(bot.TagbotConfig{
-				Repo: repo,
+				MonorepoConfig:           monorepoConf,
+				LintConfig:               lintConf,
+				AllowedGeneratedMessages: allowedGenerated,
+				Repo:                     repo,
 			})
 
 			content, err := os.ReadFile(args[0])
@@ -57,5 +104,10 @@ func CommitMessage() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().Bool(config.MonoRepo, config.DefaultMonoRepo, "Indicates this repo is a monorepo, and commit scopes should be validated against its components")
+	cmd.Flags().String(config.MonoRepoConfigPath, config.DefaultMonoRepoConfigPath, "Path to monorepo configuration file")
+	cmd.Flags().String(config.LintConfigPath, config.DefaultLintConfigPath, "Path to configuration file containing commit message lint rules")
+	cmd.Flags().StringSlice(config.AllowedGeneratedMessages, config.DefaultAllowedGeneratedMessages, fmt.Sprintf("Git generated messages to allow without validation, any of %v", config.GeneratedMessageKindNames()))
+
 	return cmd
 }
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 
 	"github.com/nicjohnson145/hlp"
 	"github.com/nicjohnson145/tagbot/internal/bot"
@@ -22,49 +23,107 @@ func Root() *cobra.Command {
 			// Base logging setup
 			logger := config.NewLoggerFromEnv()
 
+			walkStrategy, err := config.ParseWalkKind(viper.GetString(config.WalkStrategy))
+			if err != nil {
+				logger.Err(err).Msg("error parsing walk strategy")
+				return err
+			}
+			mergeClassification, err := config.ParseMergeKind(viper.GetString(config.MergeClassification))
+			if err != nil {
+				logger.Err(err).Msg("error parsing merge classification")
+				return err
+			}
+
+			shallowMode, err := config.ParseShallowKind(viper.GetString(config.ShallowMode))
+			if err != nil {
+				logger.Err(err).Msg("error parsing shallow mode")
+				return err
+			}
+
+			versionConflictMode, err := config.ParseVersionConflictKind(viper.GetString(config.VersionConflictMode))
+			if err != nil {
+				logger.Err(err).Msg("error parsing version conflict mode")
+				return err
+			}
+
+			// Construct our git repo
+			repo, err := bot.NewGitRepo(bot.GitRepoConfig{
+				Path:                    viper.GetString(config.RepoPath),
+				Ref:                     viper.GetString(config.Ref),
+				NoPush:                  viper.GetBool(config.NoPush),
+				UseCommitGraph:          viper.GetBool(config.UseCommitGraph),
+				FileCache:               viper.GetBool(config.FileCache),
+				FileCachePath:           viper.GetString(config.FileCachePath),
+				Remote:                  viper.GetString(config.RemoteName),
+				AuthMethod:              viper.GetString(config.AuthMethod),
+				AuthToken:               viper.GetString(config.AuthToken),
+				AuthKeyPath:             viper.GetString(config.AuthKeyPath),
+				AuthKeyPassphrase:       viper.GetString(config.AuthKeyPassphrase),
+				AuthTokenUsername:       viper.GetString(config.AuthTokenUsername),
+				GithubAppID:             viper.GetString(config.GithubAppID),
+				GithubAppInstallationID: viper.GetString(config.GithubAppInstallationID),
+				GithubAppPrivateKeyPath: viper.GetString(config.GithubAppPrivateKeyPath),
+				GithubAppPrivateKey:     viper.GetString(config.GithubAppPrivateKey),
+				GithubAPIURL:            viper.GetString(config.GithubAPIURL),
+				KnownHostsPath:          viper.GetString(config.KnownHostsPath),
+				KnownHosts:              viper.GetString(config.KnownHosts),
+				InsecureIgnoreHostKey:   viper.GetBool(config.InsecureIgnoreHostKey),
+				ShallowMode:             shallowMode,
+				RequireCleanWorktree:    viper.GetBool(config.RequireCleanWorktree),
+				RequirePushed:           viper.GetBool(config.RequirePushed),
+			})
+			if err != nil {
+				logger.Err(err).Msg("error creating git repo handle")
+				return err
+			}
+
 			// Construct our monorepo config, faking one if we're not in a monorepo
 			var monorepoConf *config.MonoRepoConfig
 			if viper.GetBool(config.MonoRepo) {
-				c, err := config.ParseMonoRepoConfig(viper.GetString(config.MonoRepoConfigPath))
+				path, err := repoRelativePath(repo, config.MonoRepoConfigPath, config.DefaultMonoRepoConfigPath)
+				if err != nil {
+					logger.Err(err).Msg("error resolving monorepo config path")
+					return err
+				}
+				c, err := config.ParseMonoRepoConfig(path)
 				if err != nil {
 					logger.Err(err).Msg("error parsing monorepo config")
 					return err
 				}
 				monorepoConf = c
 			} else {
+				releaseMode, err := config.ParseReleaseKind(viper.GetString(config.ReleaseMode))
+				if err != nil {
+					logger.Err(err).Msg("error parsing release mode")
+					return err
+				}
 				monorepoConf = &config.MonoRepoConfig{
 					Components: map[string]config.MonoRepoComponent{
 						"core": {
-							Name:           "core",
-							ChangeSetGlobs: []string{"**/*"},
-							Prefix:         hlp.Ptr(""),
-							MaintainLatest: hlp.Ptr(viper.GetBool(config.MaintainLatest)),
-							LatestName:     hlp.Ptr(viper.GetString(config.LatestName)),
-							NoV:            hlp.Ptr(viper.GetBool(config.NoV)),
-							AlwaysPatch:    hlp.Ptr(viper.GetBool(config.AlwaysPatch)),
+							Name:              "core",
+							ChangeSetGlobs:    []string{"**/*"},
+							Prefix:            hlp.Ptr(""),
+							MaintainLatest:    hlp.Ptr(viper.GetBool(config.MaintainLatest)),
+							LatestName:        hlp.Ptr(viper.GetString(config.LatestName)),
+							NoV:               hlp.Ptr(viper.GetBool(config.NoV)),
+							AlwaysPatch:       hlp.Ptr(viper.GetBool(config.AlwaysPatch)),
+							ReleaseMode:       hlp.Ptr(releaseMode),
+							ReleaseMarkerFile: hlp.Ptr(viper.GetString(config.ReleaseMarkerFile)),
 						},
 					},
 				}
 			}
 
-			// Construct our git repo
-			repo, err := bot.NewGitRepo(bot.GitRepoConfig{
-				Path:              ".", // TODO: config option
-				Remote:            viper.GetString(config.RemoteName),
-				AuthMethod:        viper.GetString(config.AuthMethod),
-				AuthToken:         viper.GetString(config.AuthToken),
-				AuthKeyPath:       viper.GetString(config.AuthKeyPath),
-				AuthTokenUsername: viper.GetString(config.AuthTokenUsername),
-			})
-			if err != nil {
-				logger.Err(err).Msg("error creating git repo handle")
-				return err
-			}
-
 			tagbot := bot.NewTagbot(bot.TagbotConfig{
-				MonorepoConfig: monorepoConf,
-				Repo:           repo,
-				DryRun:         viper.GetBool(config.DryRun),
+				MonorepoConfig:             monorepoConf,
+				WalkStrategy:               walkStrategy,
+				MergeClassification:        mergeClassification,
+				Repo:                       repo,
+				DryRun:                     viper.GetBool(config.DryRun),
+				NoPush:                     viper.GetBool(config.NoPush),
+				AllowMultipleTagsPerCommit: viper.GetBool(config.AllowMultipleTagsPerCommit),
+				AllowedBranches:            viper.GetStringSlice(config.AllowedBranches),
+				VersionConflictMode:        versionConflictMode,
 			})
 
 			// Embed our logger in a context so we can send it around
@@ -81,12 +140,24 @@ func Root() *cobra.Command {
 	}
 
 	cmd.PersistentFlags().StringP(config.LogLevel, "v", config.DefaultLogLevel, fmt.Sprintf("Logging output level, one of %v", config.LoggingLevelNames()))
+	cmd.PersistentFlags().String(config.RepoPath, config.DefaultRepoPath, "Path to the repository, or any directory within it")
+
+	cmd.Flags().String(config.Ref, config.DefaultRef, "Branch, tag, or commit to compute and create tags for, instead of HEAD")
 
 	cmd.Flags().String(config.RemoteName, config.DefaultRemoteName, "The remote name to push tags to")
 	cmd.Flags().String(config.AuthMethod, "", "Force the auth method to use to push tags, otherwise inferred from remote")
 	cmd.Flags().String(config.AuthToken, "", "The auth token to use during token based auth")
 	cmd.Flags().String(config.AuthTokenUsername, "TagBot", "The auth username to use during token based auth")
 	cmd.Flags().String(config.AuthKeyPath, "", "Path to key to use during key based auth, sane defaults used otherwise")
+	cmd.Flags().String(config.AuthKeyPassphrase, "", "Passphrase of the key used during key based auth, if it has one")
+	cmd.Flags().String(config.GithubAppID, "", "ID of the GitHub App to push tags as during token based auth")
+	cmd.Flags().String(config.GithubAppInstallationID, "", "ID of the GitHub App's installation on the repo's owner")
+	cmd.Flags().String(config.GithubAppPrivateKeyPath, "", "Path to the GitHub App's private key")
+	cmd.Flags().String(config.GithubAppPrivateKey, "", "The GitHub App's private key, instead of reading it from a file")
+	cmd.Flags().String(config.GithubAPIURL, config.DefaultGithubAPIURL, "Base URL of the GitHub API installation tokens are requested from, for GitHub Enterprise Server")
+	cmd.Flags().String(config.KnownHostsPath, config.DefaultKnownHostsPath, "Path to a known_hosts file to verify the remote's host key against during key based auth")
+	cmd.Flags().String(config.KnownHosts, config.DefaultKnownHosts, "known_hosts entries to verify the remote's host key against during key based auth")
+	cmd.Flags().Bool(config.InsecureIgnoreHostKey, config.DefaultInsecureIgnoreHostKey, "Do not verify the remote's host key during key based auth. Only intended for testing")
 
 	cmd.Flags().Bool(config.MonoRepo, config.DefaultMonoRepo, "Indicates this repo is a monorepo, and multiple tags should be managed")
 	cmd.Flags().String(config.MonoRepoConfigPath, config.DefaultMonoRepoConfigPath, "Path to monorepo configuration file")
@@ -95,10 +166,45 @@ func Root() *cobra.Command {
 	cmd.Flags().String(config.LatestName, config.DefaultLatestName, "Name of latest, if maintained. Applied to all non-overriden components in monorepo mode")
 	cmd.Flags().Bool(config.NoV, config.DefaultNoV, "Do not include the 'v' prefix on created tags. Applied to all non-overriden components in monorepo mode")
 	cmd.Flags().Bool(config.AlwaysPatch, config.DefaultAlwaysPatch, "If commits would result in no version bump, instead patch. Applied to all non-overriden components in monorepo mode")
+	cmd.Flags().String(config.ReleaseMode, config.DefaultReleaseMode, fmt.Sprintf("When to release, one of %v. Manual releases wait for a 'Release: true' trailer or a change to the release marker file. Applied to all non-overriden components in monorepo mode", config.ReleaseKindNames()))
+	cmd.Flags().String(config.ReleaseMarkerFile, config.DefaultReleaseMarkerFile, "File whose changes approve a manual release. Applied to all non-overriden components in monorepo mode")
+
+	cmd.Flags().String(config.WalkStrategy, config.DefaultWalkStrategy, fmt.Sprintf("How to walk commit history, one of %v", config.WalkKindNames()))
+	cmd.Flags().String(config.MergeClassification, config.DefaultMergeClassification, fmt.Sprintf("How merges are classified when walking first parents, one of %v", config.MergeKindNames()))
+
+	cmd.Flags().Bool(config.UseCommitGraph, config.DefaultUseCommitGraph, "Read the commit-graph file, if present, to speed up walking history")
+	cmd.Flags().Bool(config.FileCache, config.DefaultFileCache, "Cache the files changed by each commit on disk, to speed up later runs")
+	cmd.Flags().String(config.FileCachePath, config.DefaultFileCachePath, "Directory to store the file cache in, defaults to tagbot/ within the .git directory")
+
+	cmd.Flags().String(config.ShallowMode, config.DefaultShallowMode, fmt.Sprintf("What to do when ran in a shallow clone, one of %v", config.ShallowKindNames()))
 
 	cmd.Flags().Bool(config.DryRun, config.DefaultDryRun, "Do not actually make or push any tags, only log what would be done")
+	cmd.Flags().Bool(config.NoPush, config.DefaultNoPush, "Create tags in the local repository only, without pushing them. Suitable for server side hooks in bare repos")
+	cmd.Flags().StringSlice(config.AllowedBranches, config.DefaultAllowedBranches, "Globs of branches tags may be created on, defaults to the remote's default branch")
+	cmd.Flags().Bool(config.RequireCleanWorktree, config.DefaultRequireCleanWorktree, "Refuse to tag if tracked files have uncommitted changes")
+	cmd.Flags().Bool(config.RequirePushed, config.DefaultRequirePushed, "Refuse to tag unless the branch being tagged on the remote contains the commit, after fetching it")
+	cmd.Flags().Bool(config.AllowMultipleTagsPerCommit, config.DefaultAllowMultipleTagsPerCommit, "Tag components even if the commit being tagged already has a version tag for them")
+	cmd.Flags().String(config.VersionConflictMode, config.DefaultVersionConflictMode, fmt.Sprintf("What to do when a new tag already exists or sorts below an existing one, one of %v", config.VersionConflictKindNames()))
 
 	cmd.AddCommand(CommitMessage())
 
 	return cmd
 }
+
+// repoRelativePath returns the path configured under the key. A path left at its default is resolved against the root of
+// the repo rather than the working directory, so the same file is found from any subdirectory, or with --repo-path
+func repoRelativePath(repo *bot.GitRepo, key string, def string) (string, error) {
+	path := viper.GetString(key)
+	if path != def {
+		return path, nil
+	}
+
+	root, err := repo.Root()
+	if err != nil {
+		return "", err
+	}
+	if root == "" {
+		return path, nil
+	}
+	return filepath.Join(root, path), nil
+}
@@ -3,7 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
-	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -35,6 +35,17 @@ const (
 	AuthToken         = "auth-token"
 	AuthTokenUsername = "auth-token-username"
 	AuthKeyPath       = "auth-key-path"
+	AuthKeyPassphrase = "auth-key-passphrase"
+
+	GithubAppID             = "github-app-id"
+	GithubAppInstallationID = "github-app-installation-id"
+	GithubAppPrivateKeyPath = "github-app-private-key-path"
+	GithubAppPrivateKey     = "github-app-private-key"
+	GithubAPIURL            = "github-api-url"
+
+	KnownHostsPath        = "known-hosts-path"
+	KnownHosts            = "known-hosts"
+	InsecureIgnoreHostKey = "insecure-ignore-host-key"
 
 	MonoRepo           = "monorepo"
 	MonoRepoConfigPath = "monorepo-config-path"
@@ -44,7 +55,34 @@ const (
 	NoV            = "no-v"
 	AlwaysPatch    = "always-patch"
 
+	ReleaseMode       = "release-mode"
+	ReleaseMarkerFile = "release-marker-file"
+
 	DryRun = "dry-run"
+
+	AllowMultipleTagsPerCommit = "allow-multiple-tags-per-commit"
+	AllowedBranches            = "allowed-branches"
+	RequireCleanWorktree       = "require-clean-worktree"
+	RequirePushed              = "require-pushed"
+	VersionConflictMode        = "version-conflict-mode"
+
+	LintConfigPath = "lint-config-path"
+
+	AllowedGeneratedMessages = "allowed-generated-messages"
+
+	WalkStrategy        = "walk-strategy"
+	MergeClassification = "merge-classification"
+
+	ShallowMode = "shallow-mode"
+
+	RepoPath = "repo-path"
+	Ref      = "ref"
+	NoPush   = "no-push"
+
+	UseCommitGraph = "use-commit-graph"
+
+	FileCache     = "file-cache"
+	FileCachePath = "file-cache-path"
 )
 
 var (
@@ -53,6 +91,12 @@ var (
 	DefaultRemoteName        = "origin"
 	DefaultAuthTokenUsername = "TagBot"
 
+	DefaultGithubAPIURL = "https://api.github.com"
+
+	DefaultKnownHostsPath        = ""
+	DefaultKnownHosts            = ""
+	DefaultInsecureIgnoreHostKey = false
+
 	DefaultMonoRepo           = false
 	DefaultMonoRepoConfigPath = "./.tagbot.yaml"
 
@@ -61,43 +105,48 @@ var (
 	DefaultNoV            = false
 	DefaultAlwaysPatch    = false
 
+	DefaultReleaseMode       = ReleaseKindAuto.String()
+	DefaultReleaseMarkerFile = ""
+
 	DefaultDryRun = false
-)
 
-func InitConfig(cmd *cobra.Command) error {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("error getting user home directory: %w", err)
-	}
+	DefaultAllowMultipleTagsPerCommit = false
+	DefaultAllowedBranches            = []string{}
+	DefaultRequireCleanWorktree       = false
+	DefaultRequirePushed              = false
+	DefaultVersionConflictMode        = VersionConflictKindFail.String()
 
-	setDefaultKeyPath := func() error {
-		options := []string{
-			filepath.Join(home, ".ssh", "id_rsa"),
-			filepath.Join(home, ".ssh", "id_ecdsa"),
-		}
+	DefaultLintConfigPath = "./.tagbot.yaml"
 
-		for _, option := range options {
-			if _, err := os.Stat(option); err == nil {
-				viper.SetDefault(AuthKeyPath, option)
-				return nil
-			} else {
-				if !os.IsNotExist(err) {
-					return fmt.Errorf("error checking existence of %v: %w", option, err)
-				}
-			}
-		}
+	DefaultAllowedGeneratedMessages = GeneratedMessageKindNames()
 
-		return nil
-	}
-	if err := setDefaultKeyPath(); err != nil {
-		return err
-	}
+	DefaultWalkStrategy        = WalkKindAll.String()
+	DefaultMergeClassification = MergeKindMessage.String()
+
+	DefaultShallowMode = ShallowKindFail.String()
+
+	DefaultRepoPath = "."
+	DefaultRef      = ""
+	DefaultNoPush   = false
 
+	DefaultUseCommitGraph = false
+
+	DefaultFileCache     = false
+	DefaultFileCachePath = ""
+)
+
+func InitConfig(cmd *cobra.Command) error {
 	viper.SetDefault(LogLevel, DefaultLogLevel)
 
 	viper.SetDefault(RemoteName, DefaultRemoteName)
 	viper.SetDefault(AuthTokenUsername, DefaultAuthTokenUsername)
 
+	viper.SetDefault(GithubAPIURL, DefaultGithubAPIURL)
+
+	viper.SetDefault(KnownHostsPath, DefaultKnownHostsPath)
+	viper.SetDefault(KnownHosts, DefaultKnownHosts)
+	viper.SetDefault(InsecureIgnoreHostKey, DefaultInsecureIgnoreHostKey)
+
 	viper.SetDefault(MonoRepo, DefaultMonoRepo)
 	viper.SetDefault(MonoRepoConfigPath, DefaultMonoRepoConfigPath)
 
@@ -106,8 +155,35 @@ func InitConfig(cmd *cobra.Command) error {
 	viper.SetDefault(NoV, DefaultNoV)
 	viper.SetDefault(AlwaysPatch, DefaultAlwaysPatch)
 
+	viper.SetDefault(ReleaseMode, DefaultReleaseMode)
+	viper.SetDefault(ReleaseMarkerFile, DefaultReleaseMarkerFile)
+
 	viper.SetDefault(DryRun, DefaultDryRun)
 
+	viper.SetDefault(AllowMultipleTagsPerCommit, DefaultAllowMultipleTagsPerCommit)
+	viper.SetDefault(AllowedBranches, DefaultAllowedBranches)
+	viper.SetDefault(RequireCleanWorktree, DefaultRequireCleanWorktree)
+	viper.SetDefault(RequirePushed, DefaultRequirePushed)
+	viper.SetDefault(VersionConflictMode, DefaultVersionConflictMode)
+
+	viper.SetDefault(LintConfigPath, DefaultLintConfigPath)
+
+	viper.SetDefault(AllowedGeneratedMessages, DefaultAllowedGeneratedMessages)
+
+	viper.SetDefault(WalkStrategy, DefaultWalkStrategy)
+	viper.SetDefault(MergeClassification, DefaultMergeClassification)
+
+	viper.SetDefault(ShallowMode, DefaultShallowMode)
+
+	viper.SetDefault(RepoPath, DefaultRepoPath)
+	viper.SetDefault(Ref, DefaultRef)
+	viper.SetDefault(NoPush, DefaultNoPush)
+
+	viper.SetDefault(UseCommitGraph, DefaultUseCommitGraph)
+
+	viper.SetDefault(FileCache, DefaultFileCache)
+	viper.SetDefault(FileCachePath, DefaultFileCachePath)
+
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 	if err := viper.BindPFlags(cmd.Flags()); err != nil {
@@ -158,18 +234,38 @@ func NewLogger(opts LoggerOpts) zerolog.Logger {
 	return logger
 }
 
+/*
+ENUM(
+scope
+scope-and-path
+)
+*/
+type ScopeMode string
+
 type MonoRepoConfig struct {
 	Components map[string]MonoRepoComponent `yaml:"components"`
+	ScopeMode  ScopeMode                    `yaml:"scope-mode,omitempty"`
 }
 
+/*
+ENUM(
+auto
+manual
+)
+*/
+type ReleaseKind string
+
 type MonoRepoComponent struct {
-	Name           string   `yaml:"-"`
-	ChangeSetGlobs []string `yaml:"change-set-globs"`
-	Prefix         *string  `yaml:"prefix,omitempty"`
-	MaintainLatest *bool    `yaml:"maintain-latest,omitempty"`
-	LatestName     *string  `yaml:"latest-name,omitempty"`
-	NoV            *bool    `yaml:"no-v,omitempty"`
-	AlwaysPatch    *bool    `yaml:"always-patch,omitempty"`
+	Name              string       `yaml:"-"`
+	ChangeSetGlobs    []string     `yaml:"change-set-globs"`
+	Scopes            []string     `yaml:"scopes,omitempty"`
+	Prefix            *string      `yaml:"prefix,omitempty"`
+	MaintainLatest    *bool        `yaml:"maintain-latest,omitempty"`
+	LatestName        *string      `yaml:"latest-name,omitempty"`
+	NoV               *bool        `yaml:"no-v,omitempty"`
+	AlwaysPatch       *bool        `yaml:"always-patch,omitempty"`
+	ReleaseMode       *ReleaseKind `yaml:"release-mode,omitempty"`
+	ReleaseMarkerFile *string      `yaml:"release-marker-file,omitempty"`
 }
 
 func ParseMonoRepoConfig(path string) (*MonoRepoConfig, error) {
@@ -184,6 +280,19 @@ func ParseMonoRepoConfig(path string) (*MonoRepoConfig, error) {
 	}
 
 	// Validate & post-process the loaded config
+	if conf.ScopeMode == "" {
+		conf.ScopeMode = ScopeModeScope
+	}
+
+	releaseMode := ReleaseKindAuto
+	if name := viper.GetString(ReleaseMode); name != "" {
+		mode, err := ParseReleaseKind(name)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing release mode: %w", err)
+		}
+		releaseMode = mode
+	}
+
 	for name := range conf.Components {
 		component := conf.Components[name]
 
@@ -203,6 +312,12 @@ func ParseMonoRepoConfig(path string) (*MonoRepoConfig, error) {
 		if component.AlwaysPatch == nil {
 			component.AlwaysPatch = hlp.Ptr(viper.GetBool(AlwaysPatch))
 		}
+		if component.ReleaseMode == nil {
+			component.ReleaseMode = hlp.Ptr(releaseMode)
+		}
+		if component.ReleaseMarkerFile == nil {
+			component.ReleaseMarkerFile = hlp.Ptr(viper.GetString(ReleaseMarkerFile))
+		}
 
 		conf.Components[name] = component
 	}
@@ -210,6 +325,168 @@ func ParseMonoRepoConfig(path string) (*MonoRepoConfig, error) {
 	return conf, nil
 }
 
+/*
+ENUM(
+all
+first-parent
+topological
+)
+*/
+type WalkKind string
+
+/*
+ENUM(
+message
+branch
+)
+*/
+type MergeKind string
+
+/*
+ENUM(
+fail
+deepen
+)
+*/
+type ShallowKind string
+
+/*
+ENUM(
+fail
+skip
+allow
+)
+*/
+type VersionConflictKind string
+
+/*
+ENUM(
+merge
+revert
+fixup
+squash
+amend
+)
+*/
+type GeneratedMessageKind string
+
+// ParseGeneratedMessageKinds parses a list of message kinds, as given on the command line
+func ParseGeneratedMessageKinds(names []string) ([]GeneratedMessageKind, error) {
+	kinds := []GeneratedMessageKind{}
+	for _, name := range names {
+		kind, err := ParseGeneratedMessageKind(name)
+		if err != nil {
+			return nil, err
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+/*
+ENUM(
+warning
+error
+)
+*/
+type LintSeverity string
+
+type LintRule struct {
+	Severity LintSeverity `yaml:"severity,omitempty"`
+}
+
+type LengthLintRule struct {
+	LintRule `yaml:",inline"`
+	Length   int `yaml:"length"`
+}
+
+type ScopesLintRule struct {
+	LintRule `yaml:",inline"`
+	Scopes   []string `yaml:"scopes"`
+}
+
+type TrailerLintRule struct {
+	LintRule `yaml:",inline"`
+	Trailer  string `yaml:"trailer"`
+	Pattern  string `yaml:"pattern,omitempty"`
+}
+
+// LintConfig contains the rules the commit-msg hook applies on top of the conventional commit format. A nil rule is
+// disabled
+type LintConfig struct {
+	HeaderMaxLength         *LengthLintRule  `yaml:"header-max-length,omitempty"`
+	ScopeEnum               *ScopesLintRule  `yaml:"scope-enum,omitempty"`
+	ScopeRequired           *LintRule        `yaml:"scope-required,omitempty"`
+	SubjectNoTrailingPeriod *LintRule        `yaml:"subject-no-trailing-period,omitempty"`
+	SubjectLowerCase        *LintRule        `yaml:"subject-lower-case,omitempty"`
+	BreakingChangeBody      *LintRule        `yaml:"breaking-change-body,omitempty"`
+	TrailerRequired         *TrailerLintRule `yaml:"trailer-required,omitempty"`
+	BodyLeadingBlank        *LintRule        `yaml:"body-leading-blank,omitempty"`
+}
+
+func ParseLintConfig(path string) (*LintConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config: %w", err)
+	}
+
+	conf := struct {
+		Lint LintConfig `yaml:"lint"`
+	}{}
+	if err := yaml.Unmarshal(content, &conf); err != nil {
+		return nil, fmt.Errorf("error unmarshalling: %w", err)
+	}
+	lint := &conf.Lint
+
+	// Rules default to being errors, and any severity given must be a known one
+	for _, rule := range lint.rules() {
+		if rule.Severity == "" {
+			rule.Severity = LintSeverityError
+			continue
+		}
+		if _, err := ParseLintSeverity(string(rule.Severity)); err != nil {
+			return nil, fmt.Errorf("invalid severity: %w", err)
+		}
+	}
+
+	if lint.HeaderMaxLength != nil && lint.HeaderMaxLength.Length <= 0 {
+		return nil, fmt.Errorf("header-max-length: length must be positive")
+	}
+	if lint.ScopeEnum != nil && len(lint.ScopeEnum.Scopes) == 0 {
+		return nil, fmt.Errorf("scope-enum: no scopes configured")
+	}
+	if lint.TrailerRequired != nil {
+		if lint.TrailerRequired.Trailer == "" {
+			return nil, fmt.Errorf("trailer-required: no trailer configured")
+		}
+		if _, err := regexp.Compile(lint.TrailerRequired.Pattern); err != nil {
+			return nil, fmt.Errorf("trailer-required: invalid pattern: %w", err)
+		}
+	}
+
+	return lint, nil
+}
+
+// rules returns the common portion of every enabled rule
+func (l *LintConfig) rules() []*LintRule {
+	rules := []*LintRule{}
+	if l.HeaderMaxLength != nil {
+		rules = append(rules, &l.HeaderMaxLength.LintRule)
+	}
+	if l.ScopeEnum != nil {
+		rules = append(rules, &l.ScopeEnum.LintRule)
+	}
+	if l.TrailerRequired != nil {
+		rules = append(rules, &l.TrailerRequired.LintRule)
+	}
+	for _, rule := range []*LintRule{l.ScopeRequired, l.SubjectNoTrailingPeriod, l.SubjectLowerCase, l.BreakingChangeBody, l.BodyLeadingBlank} {
+		if rule != nil {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
 /*
 ENUM(
 ssh
@@ -221,7 +498,9 @@ type RemoteType string
 /*
 ENUM(
 public-key
+ssh-agent
 token
+none
 )
 */
 type AuthKind string
@@ -229,4 +508,5 @@ type AuthKind string
 var AuthToRemoteMap = map[AuthKind]RemoteType{
 	AuthKindToken:     RemoteTypeHttps,
 	AuthKindPublicKey: RemoteTypeSsh,
+	AuthKindSshAgent:  RemoteTypeSsh,
 }
@@ -14,15 +14,21 @@ import (
 const (
 	// AuthKindPublicKey is a AuthKind of type public-key.
 	AuthKindPublicKey AuthKind = "public-key"
+	// AuthKindSshAgent is a AuthKind of type ssh-agent.
+	AuthKindSshAgent AuthKind = "ssh-agent"
 	// AuthKindToken is a AuthKind of type token.
 	AuthKindToken AuthKind = "token"
+	// AuthKindNone is a AuthKind of type none.
+	AuthKindNone AuthKind = "none"
 )
 
 var ErrInvalidAuthKind = fmt.Errorf("not a valid AuthKind, try [%s]", strings.Join(_AuthKindNames, ", "))
 
 var _AuthKindNames = []string{
 	string(AuthKindPublicKey),
+	string(AuthKindSshAgent),
 	string(AuthKindToken),
+	string(AuthKindNone),
 }
 
 // AuthKindNames returns a list of possible string values of AuthKind.
@@ -46,7 +52,9 @@ func (x AuthKind) IsValid() bool {
 
 var _AuthKindValue = map[string]AuthKind{
 	"public-key": AuthKindPublicKey,
+	"ssh-agent":  AuthKindSshAgent,
 	"token":      AuthKindToken,
+	"none":       AuthKindNone,
 }
 
 // ParseAuthKind attempts to convert a string to a AuthKind.
@@ -80,6 +88,156 @@ func (x *AuthKind) AppendText(b []byte) ([]byte, error) {
 	return append(b, x.String()...), nil
 }
 
+const (
+	// GeneratedMessageKindMerge is a GeneratedMessageKind of type merge.
+	GeneratedMessageKindMerge GeneratedMessageKind = "merge"
+	// GeneratedMessageKindRevert is a GeneratedMessageKind of type revert.
+	GeneratedMessageKindRevert GeneratedMessageKind = "revert"
+	// GeneratedMessageKindFixup is a GeneratedMessageKind of type fixup.
+	GeneratedMessageKindFixup GeneratedMessageKind = "fixup"
+	// GeneratedMessageKindSquash is a GeneratedMessageKind of type squash.
+	GeneratedMessageKindSquash GeneratedMessageKind = "squash"
+	// GeneratedMessageKindAmend is a GeneratedMessageKind of type amend.
+	GeneratedMessageKindAmend GeneratedMessageKind = "amend"
+)
+
+var ErrInvalidGeneratedMessageKind = fmt.Errorf("not a valid GeneratedMessageKind, try [%s]", strings.Join(_GeneratedMessageKindNames, ", "))
+
+var _GeneratedMessageKindNames = []string{
+	string(GeneratedMessageKindMerge),
+	string(GeneratedMessageKindRevert),
+	string(GeneratedMessageKindFixup),
+	string(GeneratedMessageKindSquash),
+	string(GeneratedMessageKindAmend),
+}
+
+// GeneratedMessageKindNames returns a list of possible string values of GeneratedMessageKind.
+func GeneratedMessageKindNames() []string {
+	tmp := make([]string, len(_GeneratedMessageKindNames))
+	copy(tmp, _GeneratedMessageKindNames)
+	return tmp
+}
+
+// String implements the Stringer interface.
+func (x GeneratedMessageKind) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x GeneratedMessageKind) IsValid() bool {
+	_, err := ParseGeneratedMessageKind(string(x))
+	return err == nil
+}
+
+var _GeneratedMessageKindValue = map[string]GeneratedMessageKind{
+	"merge":  GeneratedMessageKindMerge,
+	"revert": GeneratedMessageKindRevert,
+	"fixup":  GeneratedMessageKindFixup,
+	"squash": GeneratedMessageKindSquash,
+	"amend":  GeneratedMessageKindAmend,
+}
+
+// ParseGeneratedMessageKind attempts to convert a string to a GeneratedMessageKind.
+func ParseGeneratedMessageKind(name string) (GeneratedMessageKind, error) {
+	if x, ok := _GeneratedMessageKindValue[name]; ok {
+		return x, nil
+	}
+	return GeneratedMessageKind(""), fmt.Errorf("%s is %w", name, ErrInvalidGeneratedMessageKind)
+}
+
+// MarshalText implements the text marshaller method.
+func (x GeneratedMessageKind) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *GeneratedMessageKind) UnmarshalText(text []byte) error {
+	tmp, err := ParseGeneratedMessageKind(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *GeneratedMessageKind) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// LintSeverityWarning is a LintSeverity of type warning.
+	LintSeverityWarning LintSeverity = "warning"
+	// LintSeverityError is a LintSeverity of type error.
+	LintSeverityError LintSeverity = "error"
+)
+
+var ErrInvalidLintSeverity = fmt.Errorf("not a valid LintSeverity, try [%s]", strings.Join(_LintSeverityNames, ", "))
+
+var _LintSeverityNames = []string{
+	string(LintSeverityWarning),
+	string(LintSeverityError),
+}
+
+// LintSeverityNames returns a list of possible string values of LintSeverity.
+func LintSeverityNames() []string {
+	tmp := make([]string, len(_LintSeverityNames))
+	copy(tmp, _LintSeverityNames)
+	return tmp
+}
+
+// String implements the Stringer interface.
+func (x LintSeverity) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x LintSeverity) IsValid() bool {
+	_, err := ParseLintSeverity(string(x))
+	return err == nil
+}
+
+var _LintSeverityValue = map[string]LintSeverity{
+	"warning": LintSeverityWarning,
+	"error":   LintSeverityError,
+}
+
+// ParseLintSeverity attempts to convert a string to a LintSeverity.
+func ParseLintSeverity(name string) (LintSeverity, error) {
+	if x, ok := _LintSeverityValue[name]; ok {
+		return x, nil
+	}
+	return LintSeverity(""), fmt.Errorf("%s is %w", name, ErrInvalidLintSeverity)
+}
+
+// MarshalText implements the text marshaller method.
+func (x LintSeverity) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *LintSeverity) UnmarshalText(text []byte) error {
+	tmp, err := ParseLintSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *LintSeverity) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
 const (
 	// LoggingLevelTrace is a LoggingLevel of type trace.
 	LoggingLevelTrace LoggingLevel = "trace"
@@ -161,6 +319,144 @@ func (x *LoggingLevel) AppendText(b []byte) ([]byte, error) {
 	return append(b, x.String()...), nil
 }
 
+const (
+	// MergeKindMessage is a MergeKind of type message.
+	MergeKindMessage MergeKind = "message"
+	// MergeKindBranch is a MergeKind of type branch.
+	MergeKindBranch MergeKind = "branch"
+)
+
+var ErrInvalidMergeKind = fmt.Errorf("not a valid MergeKind, try [%s]", strings.Join(_MergeKindNames, ", "))
+
+var _MergeKindNames = []string{
+	string(MergeKindMessage),
+	string(MergeKindBranch),
+}
+
+// MergeKindNames returns a list of possible string values of MergeKind.
+func MergeKindNames() []string {
+	tmp := make([]string, len(_MergeKindNames))
+	copy(tmp, _MergeKindNames)
+	return tmp
+}
+
+// String implements the Stringer interface.
+func (x MergeKind) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x MergeKind) IsValid() bool {
+	_, err := ParseMergeKind(string(x))
+	return err == nil
+}
+
+var _MergeKindValue = map[string]MergeKind{
+	"message": MergeKindMessage,
+	"branch":  MergeKindBranch,
+}
+
+// ParseMergeKind attempts to convert a string to a MergeKind.
+func ParseMergeKind(name string) (MergeKind, error) {
+	if x, ok := _MergeKindValue[name]; ok {
+		return x, nil
+	}
+	return MergeKind(""), fmt.Errorf("%s is %w", name, ErrInvalidMergeKind)
+}
+
+// MarshalText implements the text marshaller method.
+func (x MergeKind) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *MergeKind) UnmarshalText(text []byte) error {
+	tmp, err := ParseMergeKind(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *MergeKind) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// ReleaseKindAuto is a ReleaseKind of type auto.
+	ReleaseKindAuto ReleaseKind = "auto"
+	// ReleaseKindManual is a ReleaseKind of type manual.
+	ReleaseKindManual ReleaseKind = "manual"
+)
+
+var ErrInvalidReleaseKind = fmt.Errorf("not a valid ReleaseKind, try [%s]", strings.Join(_ReleaseKindNames, ", "))
+
+var _ReleaseKindNames = []string{
+	string(ReleaseKindAuto),
+	string(ReleaseKindManual),
+}
+
+// ReleaseKindNames returns a list of possible string values of ReleaseKind.
+func ReleaseKindNames() []string {
+	tmp := make([]string, len(_ReleaseKindNames))
+	copy(tmp, _ReleaseKindNames)
+	return tmp
+}
+
+// String implements the Stringer interface.
+func (x ReleaseKind) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x ReleaseKind) IsValid() bool {
+	_, err := ParseReleaseKind(string(x))
+	return err == nil
+}
+
+var _ReleaseKindValue = map[string]ReleaseKind{
+	"auto":   ReleaseKindAuto,
+	"manual": ReleaseKindManual,
+}
+
+// ParseReleaseKind attempts to convert a string to a ReleaseKind.
+func ParseReleaseKind(name string) (ReleaseKind, error) {
+	if x, ok := _ReleaseKindValue[name]; ok {
+		return x, nil
+	}
+	return ReleaseKind(""), fmt.Errorf("%s is %w", name, ErrInvalidReleaseKind)
+}
+
+// MarshalText implements the text marshaller method.
+func (x ReleaseKind) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *ReleaseKind) UnmarshalText(text []byte) error {
+	tmp, err := ParseReleaseKind(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *ReleaseKind) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
 const (
 	// RemoteTypeSsh is a RemoteType of type ssh.
 	RemoteTypeSsh RemoteType = "ssh"
@@ -229,3 +525,287 @@ func (x *RemoteType) UnmarshalText(text []byte) error {
 func (x *RemoteType) AppendText(b []byte) ([]byte, error) {
 	return append(b, x.String()...), nil
 }
+
+const (
+	// ScopeModeScope is a ScopeMode of type scope.
+	ScopeModeScope ScopeMode = "scope"
+	// ScopeModeScopeAndPath is a ScopeMode of type scope-and-path.
+	ScopeModeScopeAndPath ScopeMode = "scope-and-path"
+)
+
+var ErrInvalidScopeMode = fmt.Errorf("not a valid ScopeMode, try [%s]", strings.Join(_ScopeModeNames, ", "))
+
+var _ScopeModeNames = []string{
+	string(ScopeModeScope),
+	string(ScopeModeScopeAndPath),
+}
+
+// ScopeModeNames returns a list of possible string values of ScopeMode.
+func ScopeModeNames() []string {
+	tmp := make([]string, len(_ScopeModeNames))
+	copy(tmp, _ScopeModeNames)
+	return tmp
+}
+
+// String implements the Stringer interface.
+func (x ScopeMode) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x ScopeMode) IsValid() bool {
+	_, err := ParseScopeMode(string(x))
+	return err == nil
+}
+
+var _ScopeModeValue = map[string]ScopeMode{
+	"scope":          ScopeModeScope,
+	"scope-and-path": ScopeModeScopeAndPath,
+}
+
+// ParseScopeMode attempts to convert a string to a ScopeMode.
+func ParseScopeMode(name string) (ScopeMode, error) {
+	if x, ok := _ScopeModeValue[name]; ok {
+		return x, nil
+	}
+	return ScopeMode(""), fmt.Errorf("%s is %w", name, ErrInvalidScopeMode)
+}
+
+// MarshalText implements the text marshaller method.
+func (x ScopeMode) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *ScopeMode) UnmarshalText(text []byte) error {
+	tmp, err := ParseScopeMode(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *ScopeMode) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// ShallowKindFail is a ShallowKind of type fail.
+	ShallowKindFail ShallowKind = "fail"
+	// ShallowKindDeepen is a ShallowKind of type deepen.
+	ShallowKindDeepen ShallowKind = "deepen"
+)
+
+var ErrInvalidShallowKind = fmt.Errorf("not a valid ShallowKind, try [%s]", strings.Join(_ShallowKindNames, ", "))
+
+var _ShallowKindNames = []string{
+	string(ShallowKindFail),
+	string(ShallowKindDeepen),
+}
+
+// ShallowKindNames returns a list of possible string values of ShallowKind.
+func ShallowKindNames() []string {
+	tmp := make([]string, len(_ShallowKindNames))
+	copy(tmp, _ShallowKindNames)
+	return tmp
+}
+
+// String implements the Stringer interface.
+func (x ShallowKind) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x ShallowKind) IsValid() bool {
+	_, err := ParseShallowKind(string(x))
+	return err == nil
+}
+
+var _ShallowKindValue = map[string]ShallowKind{
+	"fail":   ShallowKindFail,
+	"deepen": ShallowKindDeepen,
+}
+
+// ParseShallowKind attempts to convert a string to a ShallowKind.
+func ParseShallowKind(name string) (ShallowKind, error) {
+	if x, ok := _ShallowKindValue[name]; ok {
+		return x, nil
+	}
+	return ShallowKind(""), fmt.Errorf("%s is %w", name, ErrInvalidShallowKind)
+}
+
+// MarshalText implements the text marshaller method.
+func (x ShallowKind) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *ShallowKind) UnmarshalText(text []byte) error {
+	tmp, err := ParseShallowKind(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *ShallowKind) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// VersionConflictKindFail is a VersionConflictKind of type fail.
+	VersionConflictKindFail VersionConflictKind = "fail"
+	// VersionConflictKindSkip is a VersionConflictKind of type skip.
+	VersionConflictKindSkip VersionConflictKind = "skip"
+	// VersionConflictKindAllow is a VersionConflictKind of type allow.
+	VersionConflictKindAllow VersionConflictKind = "allow"
+)
+
+var ErrInvalidVersionConflictKind = fmt.Errorf("not a valid VersionConflictKind, try [%s]", strings.Join(_VersionConflictKindNames, ", "))
+
+var _VersionConflictKindNames = []string{
+	string(VersionConflictKindFail),
+	string(VersionConflictKindSkip),
+	string(VersionConflictKindAllow),
+}
+
+// VersionConflictKindNames returns a list of possible string values of VersionConflictKind.
+func VersionConflictKindNames() []string {
+	tmp := make([]string, len(_VersionConflictKindNames))
+	copy(tmp, _VersionConflictKindNames)
+	return tmp
+}
+
+// String implements the Stringer interface.
+func (x VersionConflictKind) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x VersionConflictKind) IsValid() bool {
+	_, err := ParseVersionConflictKind(string(x))
+	return err == nil
+}
+
+var _VersionConflictKindValue = map[string]VersionConflictKind{
+	"fail":  VersionConflictKindFail,
+	"skip":  VersionConflictKindSkip,
+	"allow": VersionConflictKindAllow,
+}
+
+// ParseVersionConflictKind attempts to convert a string to a VersionConflictKind.
+func ParseVersionConflictKind(name string) (VersionConflictKind, error) {
+	if x, ok := _VersionConflictKindValue[name]; ok {
+		return x, nil
+	}
+	return VersionConflictKind(""), fmt.Errorf("%s is %w", name, ErrInvalidVersionConflictKind)
+}
+
+// MarshalText implements the text marshaller method.
+func (x VersionConflictKind) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *VersionConflictKind) UnmarshalText(text []byte) error {
+	tmp, err := ParseVersionConflictKind(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *VersionConflictKind) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// WalkKindAll is a WalkKind of type all.
+	WalkKindAll WalkKind = "all"
+	// WalkKindFirstParent is a WalkKind of type first-parent.
+	WalkKindFirstParent WalkKind = "first-parent"
+	// WalkKindTopological is a WalkKind of type topological.
+	WalkKindTopological WalkKind = "topological"
+)
+
+var ErrInvalidWalkKind = fmt.Errorf("not a valid WalkKind, try [%s]", strings.Join(_WalkKindNames, ", "))
+
+var _WalkKindNames = []string{
+	string(WalkKindAll),
+	string(WalkKindFirstParent),
+	string(WalkKindTopological),
+}
+
+// WalkKindNames returns a list of possible string values of WalkKind.
+func WalkKindNames() []string {
+	tmp := make([]string, len(_WalkKindNames))
+	copy(tmp, _WalkKindNames)
+	return tmp
+}
+
+// String implements the Stringer interface.
+func (x WalkKind) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x WalkKind) IsValid() bool {
+	_, err := ParseWalkKind(string(x))
+	return err == nil
+}
+
+var _WalkKindValue = map[string]WalkKind{
+	"all":          WalkKindAll,
+	"first-parent": WalkKindFirstParent,
+	"topological":  WalkKindTopological,
+}
+
+// ParseWalkKind attempts to convert a string to a WalkKind.
+func ParseWalkKind(name string) (WalkKind, error) {
+	if x, ok := _WalkKindValue[name]; ok {
+		return x, nil
+	}
+	return WalkKind(""), fmt.Errorf("%s is %w", name, ErrInvalidWalkKind)
+}
+
+// MarshalText implements the text marshaller method.
+func (x WalkKind) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *WalkKind) UnmarshalText(text []byte) error {
+	tmp, err := ParseWalkKind(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *WalkKind) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
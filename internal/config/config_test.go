@@ -21,7 +21,7 @@ func TestParseMonoRepoConfig(t *testing.T) {
 			    change-set-globs:
 			    - 'bar/*'
 		`[1:])
-		require.NoError(t, os.WriteFile(dir + "/file.yaml", []byte(content), 0644))
+		require.NoError(t, os.WriteFile(dir+"/file.yaml", []byte(content), 0644))
 
 		got, err := ParseMonoRepoConfig(dir + "/file.yaml")
 		require.NoError(t, err)
@@ -34,24 +34,163 @@ func TestParseMonoRepoConfig(t *testing.T) {
 						ChangeSetGlobs: []string{
 							"foo/*",
 						},
-						MaintainLatest: hlp.Ptr(false),
-						LatestName: hlp.Ptr(""),
-						NoV: hlp.Ptr(false),
-						AlwaysPatch: hlp.Ptr(false),
+						MaintainLatest:    hlp.Ptr(false),
+						LatestName:        hlp.Ptr(""),
+						NoV:               hlp.Ptr(false),
+						AlwaysPatch:       hlp.Ptr(false),
+						ReleaseMode:       hlp.Ptr(ReleaseKindAuto),
+						ReleaseMarkerFile: hlp.Ptr(""),
 					},
 					"bar": {
 						Name: "bar",
 						ChangeSetGlobs: []string{
 							"bar/*",
 						},
-						MaintainLatest: hlp.Ptr(false),
-						LatestName: hlp.Ptr(""),
-						NoV: hlp.Ptr(false),
-						AlwaysPatch: hlp.Ptr(false),
+						MaintainLatest:    hlp.Ptr(false),
+						LatestName:        hlp.Ptr(""),
+						NoV:               hlp.Ptr(false),
+						AlwaysPatch:       hlp.Ptr(false),
+						ReleaseMode:       hlp.Ptr(ReleaseKindAuto),
+						ReleaseMarkerFile: hlp.Ptr(""),
 					},
 				},
+				ScopeMode: ScopeModeScope,
 			},
 			got,
 		)
 	})
+
+	t.Run("scopes", func(t *testing.T) {
+		dir := t.TempDir()
+		content := dedent.Dedent(`
+			scope-mode: scope-and-path
+			components:
+			  foo:
+			    change-set-globs:
+			    - 'foo/*'
+			    scopes:
+			    - foo
+			    - foo-lib
+		`[1:])
+		require.NoError(t, os.WriteFile(dir+"/file.yaml", []byte(content), 0644))
+
+		got, err := ParseMonoRepoConfig(dir + "/file.yaml")
+		require.NoError(t, err)
+		require.Equal(t, ScopeModeScopeAndPath, got.ScopeMode)
+		require.Equal(t, []string{"foo", "foo-lib"}, got.Components["foo"].Scopes)
+	})
+
+	t.Run("release mode", func(t *testing.T) {
+		dir := t.TempDir()
+		content := dedent.Dedent(`
+			components:
+			  foo:
+			    change-set-globs:
+			    - 'foo/*'
+			    release-mode: manual
+			    release-marker-file: foo/RELEASE
+			  bar:
+			    change-set-globs:
+			    - 'bar/*'
+		`[1:])
+		require.NoError(t, os.WriteFile(dir+"/file.yaml", []byte(content), 0644))
+
+		got, err := ParseMonoRepoConfig(dir + "/file.yaml")
+		require.NoError(t, err)
+		require.Equal(t, hlp.Ptr(ReleaseKindManual), got.Components["foo"].ReleaseMode)
+		require.Equal(t, hlp.Ptr("foo/RELEASE"), got.Components["foo"].ReleaseMarkerFile)
+		require.Equal(t, hlp.Ptr(ReleaseKindAuto), got.Components["bar"].ReleaseMode)
+	})
+
+	t.Run("invalid release mode", func(t *testing.T) {
+		dir := t.TempDir()
+		content := dedent.Dedent(`
+			components:
+			  foo:
+			    change-set-globs:
+			    - 'foo/*'
+			    release-mode: whenever
+		`[1:])
+		require.NoError(t, os.WriteFile(dir+"/file.yaml", []byte(content), 0644))
+
+		_, err := ParseMonoRepoConfig(dir + "/file.yaml")
+		require.ErrorIs(t, err, ErrInvalidReleaseKind)
+	})
+
+	t.Run("invalid scope mode", func(t *testing.T) {
+		dir := t.TempDir()
+		content := dedent.Dedent(`
+			scope-mode: whatever
+			components:
+			  foo:
+			    change-set-globs:
+			    - 'foo/*'
+		`[1:])
+		require.NoError(t, os.WriteFile(dir+"/file.yaml", []byte(content), 0644))
+
+		_, err := ParseMonoRepoConfig(dir + "/file.yaml")
+		require.ErrorIs(t, err, ErrInvalidScopeMode)
+	})
+}
+
+func TestParseLintConfig(t *testing.T) {
+	t.Run("smoke test", func(t *testing.T) {
+		dir := t.TempDir()
+		content := dedent.Dedent(`
+			components:
+			  foo:
+			    change-set-globs:
+			    - 'foo/*'
+			lint:
+			  header-max-length:
+			    length: 72
+			  subject-lower-case:
+			    severity: warning
+			  trailer-required:
+			    trailer: Refs
+			    pattern: '^PROJ-\d+$'
+		`[1:])
+		require.NoError(t, os.WriteFile(dir+"/file.yaml", []byte(content), 0644))
+
+		got, err := ParseLintConfig(dir + "/file.yaml")
+		require.NoError(t, err)
+		require.Equal(
+			t,
+			&LintConfig{
+				HeaderMaxLength: &LengthLintRule{
+					LintRule: LintRule{Severity: LintSeverityError},
+					Length:   72,
+				},
+				SubjectLowerCase: &LintRule{Severity: LintSeverityWarning},
+				TrailerRequired: &TrailerLintRule{
+					LintRule: LintRule{Severity: LintSeverityError},
+					Trailer:  "Refs",
+					Pattern:  `^PROJ-\d+$`,
+				},
+			},
+			got,
+		)
+	})
+
+	t.Run("no lint section", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(dir+"/file.yaml", []byte("components: {}\n"), 0644))
+
+		got, err := ParseLintConfig(dir + "/file.yaml")
+		require.NoError(t, err)
+		require.Equal(t, &LintConfig{}, got)
+	})
+
+	t.Run("invalid severity", func(t *testing.T) {
+		dir := t.TempDir()
+		content := dedent.Dedent(`
+			lint:
+			  scope-required:
+			    severity: fatal
+		`[1:])
+		require.NoError(t, os.WriteFile(dir+"/file.yaml", []byte(content), 0644))
+
+		_, err := ParseLintConfig(dir + "/file.yaml")
+		require.ErrorIs(t, err, ErrInvalidLintSeverity)
+	})
 }